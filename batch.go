@@ -0,0 +1,60 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type BatchResult struct {
+	MessageId string
+	Err       error
+}
+
+// ProduceBatch declares the configured topology once and publishes every
+// envelop back to back on the same channel. With Confirm enabled the broker
+// confirms are awaited only after the whole batch was sent. Each result lines
+// up with its envelop; the returned error is set when the topology could not
+// be declared or when any message failed.
+func (producer *Producer) ProduceBatch(ctx context.Context, messageEnvelops []MessageEnvelop, configure ConfigureProducer) ([]BatchResult, error) {
+	config := configureProducer(configure, nil)
+
+	ctx, cancel := context.WithTimeout(ctx, config.timeOut*time.Second)
+	defer cancel()
+
+	channel, queue, err := producer.declare(ctx, config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchResult, len(messageEnvelops))
+	confirmations := make([]*Confirmation, len(messageEnvelops))
+
+	for index, messageEnvelop := range messageEnvelops {
+		messageId, confirmation, err := producer.publishMessage(ctx, channel, queue, config, messageEnvelop)
+		results[index] = BatchResult{MessageId: messageId, Err: err}
+		confirmations[index] = confirmation
+	}
+
+	for index, confirmation := range confirmations {
+		if confirmation != nil {
+			results[index].Err = confirmation.Wait(ctx)
+		}
+	}
+
+	failed := 0
+
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		err := fmt.Errorf("%d of %d messages failed", failed, len(results))
+		return results, handleError(producer.logger, ErrPublish, err, "Failed to publish batch")
+	}
+
+	return results, nil
+}
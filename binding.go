@@ -0,0 +1,211 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	logging "github.com/mitz-it/golang-logging"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// bindingConfiguration binds the consumer's queue to an exchange, or, with
+// ToExchange, binds one exchange to another. Exchanges named here are not
+// declared by the consumer and must already exist.
+type bindingConfiguration struct {
+	exchange    string
+	destination string
+	toExchange  bool
+	routingKey  string
+	noWait      bool
+	arguments   *Arguments
+}
+
+func NewBindingConfiguration() *bindingConfiguration {
+	return &bindingConfiguration{
+		exchange:    "",
+		destination: "",
+		toExchange:  false,
+		routingKey:  defaultRoutingKey,
+		noWait:      defaultNoWait,
+		arguments:   nil,
+	}
+}
+
+func (config *bindingConfiguration) Exchange(exchange string) *bindingConfiguration {
+	config.exchange = exchange
+	return config
+}
+
+// ToExchange makes this an exchange-to-exchange binding: messages routed by
+// the source Exchange are forwarded to the destination exchange.
+func (config *bindingConfiguration) ToExchange(destination string) *bindingConfiguration {
+	config.destination = destination
+	config.toExchange = true
+	return config
+}
+
+func (config *bindingConfiguration) RoutingKey(routingKey string) *bindingConfiguration {
+	config.routingKey = routingKey
+	return config
+}
+
+func (config *bindingConfiguration) NoWait(noWait bool) *bindingConfiguration {
+	config.noWait = noWait
+	return config
+}
+
+// AddArguments sets the binding arguments, e.g. the x-match and header values
+// of a headers exchange binding.
+func (config *bindingConfiguration) AddArguments(args *Arguments) *bindingConfiguration {
+	config.arguments = args
+	return config
+}
+
+func (config *bindingConfiguration) validate() error {
+	if config.exchange == "" {
+		return errors.New("binding requires a source exchange")
+	}
+
+	if config.toExchange && config.destination == "" {
+		return errors.New("exchange-to-exchange binding requires a destination exchange")
+	}
+
+	return nil
+}
+
+func (config *bindingConfiguration) equals(other *bindingConfiguration) bool {
+	return config.exchange == other.exchange &&
+		config.destination == other.destination &&
+		config.toExchange == other.toExchange &&
+		config.routingKey == other.routingKey &&
+		fmt.Sprint(toArgumentsTable(config.arguments)) == fmt.Sprint(toArgumentsTable(other.arguments))
+}
+
+func (config *bindingConfiguration) bind(logger *logging.Logger, channel *amqp.Channel, queue string) error {
+	if err := config.validate(); err != nil {
+		return handleError(logger, ErrDeclaration, err, "Invalid binding")
+	}
+
+	args := toArgumentsTable(config.arguments)
+
+	if config.toExchange {
+		err := channel.ExchangeBind(config.destination, config.routingKey, config.exchange, config.noWait, args)
+		return handleError(logger, ErrDeclaration, err, "Failed to bind exchange to exchange")
+	}
+
+	err := channel.QueueBind(queue, config.routingKey, config.exchange, config.noWait, args)
+
+	return handleError(logger, ErrDeclaration, err, "Failed to bind queue to exchange")
+}
+
+func (config *bindingConfiguration) unbind(logger *logging.Logger, channel *amqp.Channel, queue string) error {
+	if err := config.validate(); err != nil {
+		return handleError(logger, ErrDeclaration, err, "Invalid binding")
+	}
+
+	args := toArgumentsTable(config.arguments)
+
+	if config.toExchange {
+		err := channel.ExchangeUnbind(config.destination, config.routingKey, config.exchange, config.noWait, args)
+		return handleError(logger, ErrDeclaration, err, "Failed to unbind exchange from exchange")
+	}
+
+	err := channel.QueueUnbind(queue, config.routingKey, config.exchange, args)
+
+	return handleError(logger, ErrDeclaration, err, "Failed to unbind queue from exchange")
+}
+
+// Bind adds a binding to a live subscription on the given queue. The binding
+// is also replayed whenever the subscription recovers.
+func (consumer *Consumer) Bind(ctx context.Context, queue string, binding *bindingConfiguration) error {
+	return consumer.rebind(ctx, queue, nil, binding)
+}
+
+// Unbind removes a binding from a live subscription on the given queue, so
+// it is not restored on recovery either.
+func (consumer *Consumer) Unbind(ctx context.Context, queue string, binding *bindingConfiguration) error {
+	return consumer.rebind(ctx, queue, binding, nil)
+}
+
+// Rebind replaces one binding with another. The new binding is added before
+// the old one is removed, so matching messages keep flowing in between.
+func (consumer *Consumer) Rebind(ctx context.Context, queue string, from *bindingConfiguration, to *bindingConfiguration) error {
+	return consumer.rebind(ctx, queue, from, to)
+}
+
+func (consumer *Consumer) rebind(ctx context.Context, queue string, from *bindingConfiguration, to *bindingConfiguration) error {
+	subs := consumer.subscriptionsOn(queue)
+
+	if len(subs) == 0 {
+		return handleError(consumer.logger, ErrConsume, fmt.Errorf("no subscription on queue %q", queue), "Failed to change bindings")
+	}
+
+	// Binding and then unbinding the same binding would leave the broker
+	// without it while recovery still restores it.
+	if from != nil && to != nil && from.equals(to) {
+		return nil
+	}
+
+	// Bindings change on a short-lived channel so that a broker error, which
+	// closes the channel, cannot interrupt deliveries of the subscription.
+	channel, err := consumer.connection.channel(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	defer channel.Close()
+
+	bind := func(binding *bindingConfiguration) error {
+		return binding.bind(consumer.logger, channel, queue)
+	}
+
+	unbind := func(binding *bindingConfiguration) error {
+		return binding.unbind(consumer.logger, channel, queue)
+	}
+
+	return replaceBindings(subs, from, to, bind, unbind)
+}
+
+// replaceBindings records each change on the subscriptions as soon as the
+// broker applied it, so a new binding stays restored on recovery even when
+// removing the old one fails.
+func replaceBindings(subs []*subscription, from *bindingConfiguration, to *bindingConfiguration, bind func(*bindingConfiguration) error, unbind func(*bindingConfiguration) error) error {
+	if to != nil {
+		if err := bind(to); err != nil {
+			return err
+		}
+
+		for _, sub := range subs {
+			sub.replaceBinding(nil, to)
+		}
+	}
+
+	if from != nil {
+		if err := unbind(from); err != nil {
+			return err
+		}
+
+		for _, sub := range subs {
+			sub.replaceBinding(from, nil)
+		}
+	}
+
+	return nil
+}
+
+func (consumer *Consumer) subscriptionsOn(queue string) []*subscription {
+	consumer.mutex.Lock()
+	defer consumer.mutex.Unlock()
+
+	var subs []*subscription
+
+	for sub := range consumer.subscriptions {
+		if sub.queue() == queue {
+			subs = append(subs, sub)
+		}
+	}
+
+	return subs
+}
@@ -0,0 +1,81 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func newBoundSubscription(bindings ...*bindingConfiguration) *subscription {
+	return &subscription{declared: "orders", bindings: bindings}
+}
+
+func routedBinding(routingKey string) *bindingConfiguration {
+	return NewBindingConfiguration().Exchange("events").RoutingKey(routingKey)
+}
+
+func hasBinding(sub *subscription, binding *bindingConfiguration) bool {
+	for _, existing := range sub.bindings {
+		if existing.equals(binding) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestRebindToSameBindingIsNoOp(t *testing.T) {
+	sub := newBoundSubscription(routedBinding("orders.*"))
+	consumer := &Consumer{
+		logger:        newTestLogger(),
+		subscriptions: map[*subscription]context.CancelFunc{sub: func() {}},
+	}
+
+	// No connection is needed, since nothing is sent to the broker.
+	if err := consumer.Rebind(context.Background(), "orders", routedBinding("orders.*"), routedBinding("orders.*")); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sub.bindings) != 1 || !hasBinding(sub, routedBinding("orders.*")) {
+		t.Fatalf("expected the binding to be kept, got %d bindings", len(sub.bindings))
+	}
+}
+
+func TestReplaceBindings(t *testing.T) {
+	failure := errors.New("channel closed")
+	succeed := func(*bindingConfiguration) error { return nil }
+	fail := func(*bindingConfiguration) error { return failure }
+
+	cases := []struct {
+		name     string
+		bind     func(*bindingConfiguration) error
+		unbind   func(*bindingConfiguration) error
+		err      error
+		keepsOld bool
+		addsNew  bool
+	}{
+		{"success", succeed, succeed, nil, false, true},
+		{"bind fails", fail, succeed, failure, true, false},
+		{"unbind fails", succeed, fail, failure, true, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sub := newBoundSubscription(routedBinding("old"))
+
+			err := replaceBindings([]*subscription{sub}, routedBinding("old"), routedBinding("new"), c.bind, c.unbind)
+
+			if err != c.err {
+				t.Fatalf("expected %v, got %v", c.err, err)
+			}
+
+			if hasBinding(sub, routedBinding("old")) != c.keepsOld {
+				t.Errorf("expected old binding recorded: %v", c.keepsOld)
+			}
+
+			if hasBinding(sub, routedBinding("new")) != c.addsNew {
+				t.Errorf("expected new binding recorded: %v", c.addsNew)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package messaging
+
+import (
+	"math"
+	"time"
+)
+
+type ConnectionEvent int
+
+const (
+	Disconnected ConnectionEvent = iota
+	Reconnecting
+	Recovered
+	ReconnectFailed
+)
+
+func (event ConnectionEvent) ToString() string {
+	events := []string{"disconnected", "reconnecting", "recovered", "reconnect-failed"}
+	return events[event]
+}
+
+type OnConnectionEvent func(event ConnectionEvent, err error)
+
+type ClientConfiguration struct {
+	ReconnectConfig   *reconnectConfiguration
+	onConnectionEvent OnConnectionEvent
+	onReturned        OnReturned
+	middlewares       []Middleware
+	interceptors      []Interceptor
+	replyQueue        bool
+}
+
+type ConfigureClient func(config *ClientConfiguration)
+
+type reconnectConfiguration struct {
+	initialDelay time.Duration
+	maxDelay     time.Duration
+	multiplier   float64
+	maxAttempts  int
+}
+
+func newClientConfiguration() *ClientConfiguration {
+	return &ClientConfiguration{
+		ReconnectConfig:   NewReconnectConfiguration(),
+		onConnectionEvent: nil,
+		onReturned:        nil,
+		middlewares:       nil,
+		interceptors:      nil,
+		replyQueue:        defaultReplyQueue,
+	}
+}
+
+func configureClient(configure []ConfigureClient) *ClientConfiguration {
+	config := newClientConfiguration()
+
+	for _, apply := range configure {
+		apply(config)
+	}
+
+	return config
+}
+
+func NewReconnectConfiguration() *reconnectConfiguration {
+	return &reconnectConfiguration{
+		initialDelay: defaultReconnectDelay,
+		maxDelay:     defaultReconnectMaxDelay,
+		multiplier:   defaultReconnectMultiplier,
+		maxAttempts:  defaultReconnectMaxAttempts,
+	}
+}
+
+func (config *reconnectConfiguration) delay(attempt int) time.Duration {
+	delay := float64(config.initialDelay) * math.Pow(config.multiplier, float64(attempt))
+	return time.Duration(math.Min(delay, float64(config.maxDelay)))
+}
+
+func (config *reconnectConfiguration) exhausted(attempt int) bool {
+	return config.maxAttempts > 0 && attempt >= config.maxAttempts
+}
+
+func (config *ClientConfiguration) OnConnectionEvent(listener OnConnectionEvent) *ClientConfiguration {
+	config.onConnectionEvent = listener
+	return config
+}
+
+// OnReturned receives mandatory publishes the broker could not route. It only
+// applies to producers. The listener runs on its own goroutine, so it may
+// publish through the same producer, but calls for different returns can run
+// concurrently and in any order.
+func (config *ClientConfiguration) OnReturned(listener OnReturned) *ClientConfiguration {
+	config.onReturned = listener
+	return config
+}
+
+// Use registers middlewares around every handler of a consumer, outside the
+// ones registered per Consume call. It only applies to consumers.
+func (config *ClientConfiguration) Use(middlewares ...Middleware) *ClientConfiguration {
+	config.middlewares = append(config.middlewares, middlewares...)
+	return config
+}
+
+// Intercept registers interceptors around every publish of a producer. It only
+// applies to producers.
+func (config *ClientConfiguration) Intercept(interceptors ...Interceptor) *ClientConfiguration {
+	config.interceptors = append(config.interceptors, interceptors...)
+	return config
+}
+
+// ExclusiveReplyQueue makes a requester receive replies on its own exclusive,
+// auto-delete queue instead of RabbitMQ direct reply-to. It only applies to
+// requesters.
+func (config *ClientConfiguration) ExclusiveReplyQueue(exclusive bool) *ClientConfiguration {
+	config.replyQueue = exclusive
+	return config
+}
+
+func (config *reconnectConfiguration) InitialDelay(delay time.Duration) *reconnectConfiguration {
+	config.initialDelay = delay
+	return config
+}
+
+func (config *reconnectConfiguration) MaxDelay(delay time.Duration) *reconnectConfiguration {
+	config.maxDelay = delay
+	return config
+}
+
+func (config *reconnectConfiguration) Multiplier(multiplier float64) *reconnectConfiguration {
+	config.multiplier = multiplier
+	return config
+}
+
+// MaxAttempts bounds how many times a lost connection is redialed; zero keeps
+// trying forever.
+func (config *reconnectConfiguration) MaxAttempts(attempts int) *reconnectConfiguration {
+	config.maxAttempts = attempts
+	return config
+}
@@ -0,0 +1,200 @@
+package messaging
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+var defaultCodecs = DefaultCodecRegistry()
+
+type Codec interface {
+	ContentType() ContentType
+	Marshal(message any) ([]byte, error)
+	Unmarshal(data []byte, message any) error
+}
+
+type JsonCodec struct{}
+
+type BytesCodec struct{}
+
+type TextCodec struct{}
+
+type ProtobufCodec struct{}
+
+type MsgpackCodec struct{}
+
+func (JsonCodec) ContentType() ContentType {
+	return ApplicationJson
+}
+
+func (JsonCodec) Marshal(message any) ([]byte, error) {
+	return json.Marshal(message)
+}
+
+func (JsonCodec) Unmarshal(data []byte, message any) error {
+	return json.Unmarshal(data, message)
+}
+
+func (BytesCodec) ContentType() ContentType {
+	return ApplicationOctetStream
+}
+
+func (BytesCodec) Marshal(message any) ([]byte, error) {
+	switch body := message.(type) {
+	case []byte:
+		return body, nil
+	case string:
+		return []byte(body), nil
+	default:
+		return nil, fmt.Errorf("bytes codec cannot marshal %T", message)
+	}
+}
+
+func (BytesCodec) Unmarshal(data []byte, message any) error {
+	target, ok := message.(*[]byte)
+
+	if !ok {
+		return fmt.Errorf("bytes codec cannot unmarshal into %T", message)
+	}
+
+	*target = data
+
+	return nil
+}
+
+func (TextCodec) ContentType() ContentType {
+	return TextPlain
+}
+
+func (TextCodec) Marshal(message any) ([]byte, error) {
+	switch text := message.(type) {
+	case string:
+		return []byte(text), nil
+	case []byte:
+		return text, nil
+	case fmt.Stringer:
+		return []byte(text.String()), nil
+	default:
+		return nil, fmt.Errorf("text codec cannot marshal %T", message)
+	}
+}
+
+func (TextCodec) Unmarshal(data []byte, message any) error {
+	switch target := message.(type) {
+	case *string:
+		*target = string(data)
+	case *[]byte:
+		*target = data
+	default:
+		return fmt.Errorf("text codec cannot unmarshal into %T", message)
+	}
+
+	return nil
+}
+
+func (ProtobufCodec) ContentType() ContentType {
+	return ApplicationProtobuf
+}
+
+func (ProtobufCodec) Marshal(message any) ([]byte, error) {
+	protoMessage, ok := message.(proto.Message)
+
+	if !ok {
+		return nil, fmt.Errorf("protobuf codec cannot marshal %T", message)
+	}
+
+	return proto.Marshal(protoMessage)
+}
+
+func (ProtobufCodec) Unmarshal(data []byte, message any) error {
+	protoMessage, ok := message.(proto.Message)
+
+	if !ok {
+		return fmt.Errorf("protobuf codec cannot unmarshal into %T", message)
+	}
+
+	return proto.Unmarshal(data, protoMessage)
+}
+
+func (MsgpackCodec) ContentType() ContentType {
+	return ApplicationMsgpack
+}
+
+func (MsgpackCodec) Marshal(message any) ([]byte, error) {
+	return msgpack.Marshal(message)
+}
+
+func (MsgpackCodec) Unmarshal(data []byte, message any) error {
+	return msgpack.Unmarshal(data, message)
+}
+
+// CodecRegistry picks a Codec from a delivery's content type. Deliveries
+// without a content type are decoded with the fallback, JSON by default.
+type CodecRegistry struct {
+	mutex    sync.RWMutex
+	codecs   map[ContentType]Codec
+	fallback Codec
+}
+
+func NewCodecRegistry(codecs ...Codec) *CodecRegistry {
+	registry := &CodecRegistry{
+		codecs:   make(map[ContentType]Codec),
+		fallback: JsonCodec{},
+	}
+
+	for _, codec := range codecs {
+		registry.Register(codec)
+	}
+
+	return registry
+}
+
+func DefaultCodecRegistry() *CodecRegistry {
+	return NewCodecRegistry(
+		JsonCodec{},
+		BytesCodec{},
+		TextCodec{},
+		ProtobufCodec{},
+		MsgpackCodec{},
+	)
+}
+
+func (registry *CodecRegistry) Register(codec Codec) *CodecRegistry {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.codecs[codec.ContentType()] = codec
+
+	return registry
+}
+
+func (registry *CodecRegistry) Fallback(codec Codec) *CodecRegistry {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.fallback = codec
+
+	return registry
+}
+
+func (registry *CodecRegistry) Lookup(contentType string) (Codec, bool) {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	if contentType == "" {
+		return registry.fallback, registry.fallback != nil
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	}
+
+	codec, ok := registry.codecs[ContentType(contentType)]
+
+	return codec, ok
+}
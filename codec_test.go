@@ -0,0 +1,157 @@
+package messaging
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type order struct {
+	Id    int    `json:"id" msgpack:"id"`
+	Label string `json:"label" msgpack:"label"`
+}
+
+func TestStructuredCodecsRoundTrip(t *testing.T) {
+	for _, codec := range []Codec{JsonCodec{}, MsgpackCodec{}} {
+		data, err := codec.Marshal(order{Id: 7, Label: "book"})
+
+		if err != nil {
+			t.Fatalf("%s: %v", codec.ContentType(), err)
+		}
+
+		decoded := order{}
+
+		if err := codec.Unmarshal(data, &decoded); err != nil || decoded != (order{Id: 7, Label: "book"}) {
+			t.Fatalf("%s: expected round trip, got %+v %v", codec.ContentType(), decoded, err)
+		}
+	}
+}
+
+func TestProtobufCodecRoundTrip(t *testing.T) {
+	codec := ProtobufCodec{}
+
+	data, err := codec.Marshal(wrapperspb.String("book"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := &wrapperspb.StringValue{}
+
+	if err := codec.Unmarshal(data, decoded); err != nil || decoded.GetValue() != "book" {
+		t.Fatalf("expected round trip, got %v %v", decoded, err)
+	}
+
+	if _, err := codec.Marshal(order{}); err == nil {
+		t.Fatal("expected non-protobuf values to be rejected")
+	}
+}
+
+func TestRawCodecs(t *testing.T) {
+	tests := []struct {
+		codec   Codec
+		message any
+		want    string
+		valid   bool
+	}{
+		{BytesCodec{}, []byte("raw"), "raw", true},
+		{BytesCodec{}, "raw", "raw", true},
+		{BytesCodec{}, order{}, "", false},
+		{TextCodec{}, "text", "text", true},
+		{TextCodec{}, []byte("text"), "text", true},
+		{TextCodec{}, errors.New("unused"), "", false},
+		{TextCodec{}, order{}, "", false},
+	}
+
+	for _, test := range tests {
+		data, err := test.codec.Marshal(test.message)
+
+		if test.valid != (err == nil) || string(data) != test.want {
+			t.Errorf("%s %T: got %q %v", test.codec.ContentType(), test.message, data, err)
+		}
+	}
+
+	var text string
+
+	if err := (TextCodec{}).Unmarshal([]byte("text"), &text); err != nil || text != "text" {
+		t.Fatalf("expected text, got %q %v", text, err)
+	}
+
+	if err := (BytesCodec{}).Unmarshal([]byte("raw"), &text); err == nil {
+		t.Fatal("expected bytes codec to reject a string target")
+	}
+}
+
+func TestCodecRegistryLookup(t *testing.T) {
+	registry := DefaultCodecRegistry()
+
+	tests := []struct {
+		contentType string
+		want        ContentType
+		found       bool
+	}{
+		{"application/json", ApplicationJson, true},
+		{"application/json; charset=utf-8", ApplicationJson, true},
+		{"Text/Plain; charset=\"utf-8\"", TextPlain, true},
+		{"application/x-protobuf", ApplicationProtobuf, true},
+		{"application/msgpack", ApplicationMsgpack, true},
+		{"", ApplicationJson, true},
+		{"application/xml", "", false},
+		{"not a media type;;", "", false},
+	}
+
+	for _, test := range tests {
+		codec, found := registry.Lookup(test.contentType)
+
+		if found != test.found {
+			t.Errorf("%q: expected found %v", test.contentType, test.found)
+			continue
+		}
+
+		if found && codec.ContentType() != test.want {
+			t.Errorf("%q: expected %s, got %s", test.contentType, test.want, codec.ContentType())
+		}
+	}
+}
+
+func TestCodecRegistryFallback(t *testing.T) {
+	registry := NewCodecRegistry(JsonCodec{}).Fallback(TextCodec{})
+
+	if codec, found := registry.Lookup(""); !found || codec.ContentType() != TextPlain {
+		t.Fatalf("expected the text fallback, got %v %v", codec, found)
+	}
+
+	registry.Fallback(nil)
+
+	if _, found := registry.Lookup(""); found {
+		t.Fatal("expected no codec without a fallback")
+	}
+
+	registry.Register(BytesCodec{})
+
+	if codec, found := registry.Lookup("application/octet-stream"); !found || codec.ContentType() != ApplicationOctetStream {
+		t.Fatalf("expected registered codec, got %v %v", codec, found)
+	}
+}
+
+func TestContentTypeKeepsCodec(t *testing.T) {
+	config := newProducerConfiguration()
+	config.ContentType(TextPlain)
+
+	body, err := config.codec.Marshal(order{Id: 1})
+
+	if err != nil || string(body) != `{"id":1,"label":""}` {
+		t.Fatalf("expected JSON body, got %q %v", body, err)
+	}
+
+	if config.contentType != TextPlain {
+		t.Fatalf("expected content type %s, got %s", TextPlain, config.contentType)
+	}
+
+	config.Codec(MsgpackCodec{})
+
+	if config.contentType != ApplicationMsgpack {
+		t.Fatalf("expected Codec to set the content type, got %s", config.contentType)
+	}
+}
@@ -0,0 +1,146 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+
+	logging "github.com/mitz-it/golang-logging"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type Confirmation struct {
+	MessageId string
+	logger    *logging.Logger
+	pending   *pendingReturn
+	done      chan struct{}
+	acked     bool
+	returned  error
+}
+
+// confirmKey identifies a publish by its delivery tag, which is only unique
+// within the channel it was published on.
+type confirmKey struct {
+	channel *amqp.Channel
+	tag     uint64
+}
+
+func newConfirmation(logger *logging.Logger, messageId string, pending *pendingReturn) *Confirmation {
+	return &Confirmation{
+		MessageId: messageId,
+		logger:    logger,
+		pending:   pending,
+		done:      make(chan struct{}),
+	}
+}
+
+// Done is closed once the broker acked or nacked the message, or the channel
+// it was published on closed.
+func (confirmation *Confirmation) Done() <-chan struct{} {
+	return confirmation.done
+}
+
+func (confirmation *Confirmation) Acked() bool {
+	<-confirmation.done
+	return confirmation.acked
+}
+
+func (confirmation *Confirmation) Wait(ctx context.Context) error {
+	select {
+	case <-confirmation.done:
+	case <-ctx.Done():
+		return handleError(confirmation.logger, ErrPublish, ctx.Err(), "Timed out waiting for publisher confirmation")
+	}
+
+	if confirmation.returned != nil {
+		confirmation.logger.Standard.Error().Err(confirmation.returned).Str("message-id", confirmation.MessageId).Msg("Message could not be routed")
+		return confirmation.returned
+	}
+
+	if !confirmation.acked {
+		err := fmt.Errorf("message %s was not confirmed", confirmation.MessageId)
+		return handleError(confirmation.logger, ErrNacked, err, "Broker nacked message")
+	}
+
+	return nil
+}
+
+// awaitConfirm registers a publish made on a confirming channel. The broker
+// ack may already have been received by listenChannel, in which case the
+// confirmation is resolved right away.
+func (producer *Producer) awaitConfirm(channel *amqp.Channel, tag uint64, messageId string, pending *pendingReturn) *Confirmation {
+	confirmation := newConfirmation(producer.logger, messageId, pending)
+	key := confirmKey{channel: channel, tag: tag}
+
+	producer.confirmsMutex.Lock()
+	early, received := producer.earlyConfirms[key]
+
+	if received {
+		delete(producer.earlyConfirms, key)
+	} else {
+		producer.pendingConfirms[key] = confirmation
+	}
+
+	producer.confirmsMutex.Unlock()
+
+	if received {
+		producer.resolve(confirmation, early.Ack)
+	}
+
+	return confirmation
+}
+
+func (producer *Producer) confirmed(channel *amqp.Channel, confirmed amqp.Confirmation) {
+	key := confirmKey{channel: channel, tag: confirmed.DeliveryTag}
+
+	producer.confirmsMutex.Lock()
+	confirmation, registered := producer.pendingConfirms[key]
+
+	if registered {
+		delete(producer.pendingConfirms, key)
+	} else {
+		producer.earlyConfirms[key] = confirmed
+	}
+
+	producer.confirmsMutex.Unlock()
+
+	if registered {
+		producer.resolve(confirmation, confirmed.Ack)
+	}
+}
+
+// abandonConfirms fails every confirmation still waiting on a channel that
+// closed before the broker answered.
+func (producer *Producer) abandonConfirms(channel *amqp.Channel) {
+	var abandoned []*Confirmation
+
+	producer.confirmsMutex.Lock()
+
+	for key, confirmation := range producer.pendingConfirms {
+		if key.channel == channel {
+			abandoned = append(abandoned, confirmation)
+			delete(producer.pendingConfirms, key)
+		}
+	}
+
+	for key := range producer.earlyConfirms {
+		if key.channel == channel {
+			delete(producer.earlyConfirms, key)
+		}
+	}
+
+	producer.confirmsMutex.Unlock()
+
+	for _, confirmation := range abandoned {
+		producer.resolve(confirmation, false)
+	}
+}
+
+func (producer *Producer) resolve(confirmation *Confirmation, acked bool) {
+	if confirmation.pending != nil {
+		confirmation.returned = confirmation.pending.err()
+		producer.untrackReturn(confirmation.MessageId)
+	}
+
+	confirmation.acked = acked
+	close(confirmation.done)
+}
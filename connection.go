@@ -1,47 +1,258 @@
 package messaging
 
 import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	logging "github.com/mitz-it/golang-logging"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-func (producer *Producer) connect() (err error) {
-	producer.connection, err = amqp.Dial(producer.connectionString)
+var errReconnectFailed = errors.New("gave up reconnecting")
+
+// connection owns the AMQP connection shared by a producer or consumer. When
+// the broker drops it, a single observer redials with exponential backoff
+// while callers of channel and wait block until it is recovered.
+type connection struct {
+	connectionString string
+	logger           *logging.Logger
+	config           *ClientConfiguration
+	mutex            sync.RWMutex
+	current          *amqp.Connection
+	ready            chan struct{}
+	done             chan struct{}
+	abandoned        chan struct{}
+	closed           bool
+	failed           error
+}
+
+func dial(logger *logging.Logger, connectionString string, config *ClientConfiguration) (*connection, error) {
+	amqpConnection, err := amqp.Dial(connectionString)
+
+	if err != nil {
+		return nil, handleError(logger, ErrConnection, err, "Failed to open AMQP connection")
+	}
+
+	conn := &connection{
+		connectionString: connectionString,
+		logger:           logger,
+		config:           config,
+		ready:            make(chan struct{}),
+		done:             make(chan struct{}),
+		abandoned:        make(chan struct{}),
+	}
+
+	conn.establish(amqpConnection)
+
+	return conn, nil
+}
+
+func (conn *connection) establish(amqpConnection *amqp.Connection) {
+	conn.mutex.Lock()
+	conn.current = amqpConnection
+	close(conn.ready)
+	conn.mutex.Unlock()
+
+	go conn.observe(amqpConnection)
+}
+
+func (conn *connection) observe(amqpConnection *amqp.Connection) {
+	err := <-amqpConnection.NotifyClose(make(chan *amqp.Error, 1))
+
+	if err == nil {
+		return
+	}
+
+	conn.mutex.Lock()
+
+	if conn.closed {
+		conn.mutex.Unlock()
+		return
+	}
+
+	conn.ready = make(chan struct{})
+	conn.mutex.Unlock()
+
+	conn.logger.Standard.Error().Err(err).Msg("Connection lost")
+	conn.emit(Disconnected, err)
+
+	conn.reconnect()
+}
+
+func (conn *connection) reconnect() {
+	reconnectConfig := conn.config.ReconnectConfig
+
+	for attempt := 0; !reconnectConfig.exhausted(attempt); attempt++ {
+		conn.emit(Reconnecting, nil)
+
+		select {
+		case <-time.After(reconnectConfig.delay(attempt)):
+		case <-conn.done:
+			return
+		}
+
+		amqpConnection, err := amqp.Dial(conn.connectionString)
+
+		if err != nil {
+			conn.logger.Standard.Error().Err(err).Int("attempt", attempt+1).Msg("Failed to reconnect")
+			continue
+		}
+
+		conn.mutex.RLock()
+		closed := conn.closed
+		conn.mutex.RUnlock()
+
+		if closed {
+			amqpConnection.Close()
+			return
+		}
+
+		conn.establish(amqpConnection)
+		conn.logger.Standard.Info().Msg("Connection recovered")
+		conn.emit(Recovered, nil)
 
-	failOnError(producer.logger, err, "Producer: Failed to open AMQP channel")
+		return
+	}
+
+	conn.mutex.Lock()
+	conn.failed = newError(ErrConnection, errReconnectFailed, "Failed to recover AMQP connection")
+	close(conn.abandoned)
+	conn.mutex.Unlock()
+
+	conn.emit(ReconnectFailed, conn.failed)
+}
+
+func (conn *connection) emit(event ConnectionEvent, err error) {
+	if conn.config.onConnectionEvent != nil {
+		conn.config.onConnectionEvent(event, err)
+	}
+}
+
+// wait blocks until a live connection is available.
+func (conn *connection) wait(ctx context.Context) (*amqp.Connection, error) {
+	for {
+		conn.mutex.RLock()
+		ready, current, closed, failed := conn.ready, conn.current, conn.closed, conn.failed
+		conn.mutex.RUnlock()
+
+		if closed {
+			return nil, newError(ErrClosed, amqp.ErrClosed, "Connection is closed")
+		}
+
+		if failed != nil {
+			return nil, failed
+		}
+
+		select {
+		case <-ready:
+			if !current.IsClosed() {
+				return current, nil
+			}
+			// The connection dropped but the observer has not reset ready yet.
+			time.Sleep(time.Millisecond)
+		case <-conn.done:
+		case <-conn.abandoned:
+		case <-ctx.Done():
+			return nil, newError(ErrConnection, ctx.Err(), "Timed out waiting for AMQP connection")
+		}
+	}
+}
+
+func (conn *connection) channel(ctx context.Context) (*amqp.Channel, error) {
+	current, err := conn.wait(ctx)
+
+	if err != nil {
+		return nil, err
+	}
 
-	go producer.observeConnection()
+	channel, err := current.Channel()
 
-	producer.channel, err = producer.connection.Channel()
+	if err != nil {
+		return nil, handleError(conn.logger, ErrConnection, err, "Failed to open channel")
+	}
+
+	return channel, nil
+}
 
-	failOnError(producer.logger, err, "Producer: Failed to open channel")
+func (conn *connection) isConnected() bool {
+	conn.mutex.RLock()
+	defer conn.mutex.RUnlock()
 
-	return nil
+	return !conn.closed && !conn.current.IsClosed()
 }
 
-func (producer *Producer) observeConnection() {
-	<-producer.connection.NotifyClose(make(chan *amqp.Error))
-	for err := producer.connect(); err != nil; err = producer.connect() {
-		producer.logger.Standard.Error().AnErr("producer-reconnection", err)
+func (conn *connection) close(ctx context.Context) error {
+	conn.mutex.Lock()
+
+	if conn.closed {
+		conn.mutex.Unlock()
+		return nil
 	}
+
+	conn.closed = true
+	close(conn.done)
+	current := conn.current
+	conn.mutex.Unlock()
+
+	return closeConnection(ctx, conn.logger, current)
 }
 
-func (consumer *Consumer) connect() (err error) {
-	consumer.connection, err = amqp.Dial(consumer.connectionString)
+func (producer *Producer) getChannel(ctx context.Context, confirm bool) (*amqp.Channel, error) {
+	producer.mutex.Lock()
+	defer producer.mutex.Unlock()
+
+	if producer.channel == nil || producer.channel.IsClosed() {
+		channel, err := producer.connection.channel(ctx)
+
+		if err != nil {
+			return nil, err
+		}
 
-	failOnError(consumer.logger, err, "Consumer: Failed to open AMQP channel")
+		if producer.onChannelOpened != nil {
+			if err := producer.onChannelOpened(channel); err != nil {
+				channel.Close()
+				return nil, err
+			}
+		}
 
-	go consumer.observeConnection()
+		producer.channel = channel
+		producer.confirming = false
+		producer.declared.reset(channel)
 
-	consumer.channel, err = consumer.connection.Channel()
+		returns := channel.NotifyReturn(make(chan amqp.Return))
+		confirms := channel.NotifyPublish(make(chan amqp.Confirmation))
 
-	failOnError(consumer.logger, err, "Consumer: Failed to open channel")
+		go producer.listenChannel(channel, returns, confirms)
+	}
+
+	if confirm && !producer.confirming {
+		if err := producer.channel.Confirm(false); err != nil {
+			return nil, handleError(producer.logger, ErrConnection, err, "Producer: Failed to enable publisher confirms")
+		}
+
+		producer.confirming = true
+	}
 
-	return nil
+	return producer.channel, nil
 }
 
-func (consumer *Consumer) observeConnection() {
-	<-consumer.connection.NotifyClose(make(chan *amqp.Error))
-	for err := consumer.connect(); err != nil; err = consumer.connect() {
-		consumer.logger.Standard.Error().AnErr("consumer-reconnection", err)
+func closeConnection(ctx context.Context, logger *logging.Logger, connection *amqp.Connection) error {
+	if connection == nil || connection.IsClosed() {
+		return nil
+	}
+
+	closed := make(chan error, 1)
+
+	go func() {
+		closed <- connection.Close()
+	}()
+
+	select {
+	case err := <-closed:
+		return handleError(logger, ErrConnection, err, "Failed to close AMQP connection")
+	case <-ctx.Done():
+		return handleError(logger, ErrConnection, ctx.Err(), "Timed out closing AMQP connection")
 	}
 }
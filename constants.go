@@ -16,12 +16,33 @@ const defaultRoutingKey string = ""
 const defaultPrefetchCount int = 1
 const defaultPrefetchSize int = 0
 const defaultGlobalQos bool = false
+const defaultConcurrency int = 1
+const defaultReplyQueue bool = false
+const defaultGatherTimeout time.Duration = 5 * time.Second
+const defaultGatherBuffer int = 256
+const defaultConfirm bool = false
 
 const emptyExchangeName string = ""
 
 const defaultContextTimeOut time.Duration = 30
+const defaultReconnectDelay time.Duration = time.Second
+const defaultReconnectMaxDelay time.Duration = time.Minute
+const defaultReconnectMultiplier float64 = 2
+const defaultReconnectMaxAttempts int = 0
+const defaultDrainTimeout time.Duration = 30 * time.Second
+const defaultFailureOutcome Outcome = Reject
+const defaultPanicOutcome Outcome = Reject
+
+const defaultRetryMaxAttempts int = 5
+const defaultRetryInitialDelay time.Duration = time.Second
+const defaultRetryMaxDelay time.Duration = 5 * time.Minute
+const defaultRetryMultiplier float64 = 2
+const defaultRetryJitter float64 = 0.2
 
 type ContentType string
 
 const ApplicationJson ContentType = "application/json"
 const TextPlain ContentType = "text/plain"
+const ApplicationOctetStream ContentType = "application/octet-stream"
+const ApplicationProtobuf ContentType = "application/x-protobuf"
+const ApplicationMsgpack ContentType = "application/msgpack"
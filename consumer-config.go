@@ -1,6 +1,9 @@
 package messaging
 
 import (
+	"time"
+
+	"github.com/google/uuid"
 	logging "github.com/mitz-it/golang-logging"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
@@ -9,6 +12,7 @@ type ConsumerConfiguration struct {
 	QueueConfig      *queueConfiguration
 	ExchangeConfig   *exchangeConfiguration
 	QosConfig        *qosConfiguration
+	RetryConfig      *retryConfiguration
 	consumerIdentity string
 	routingKey       string
 	autoAck          bool
@@ -16,6 +20,16 @@ type ConsumerConfiguration struct {
 	noLocal          bool
 	noWait           bool
 	arguments        *Arguments
+	drainTimeout     time.Duration
+	failureOutcome   Outcome
+	panicOutcome     Outcome
+	maxDeliveries    int64
+	codecs           *CodecRegistry
+	concurrency      int
+	metrics          *WorkerMetrics
+	partitionKey     PartitionKey
+	middlewares      []Middleware
+	bindings         []*bindingConfiguration
 }
 
 type ConfigureConsumer func(config *ConsumerConfiguration)
@@ -25,6 +39,7 @@ func newConsumerConfiguration() *ConsumerConfiguration {
 		ExchangeConfig:   nil,
 		QueueConfig:      NewQueueConfiguration(),
 		QosConfig:        NewQosConfiguration(),
+		RetryConfig:      nil,
 		consumerIdentity: defaultConsumerIdentity,
 		routingKey:       defaultRoutingKey,
 		autoAck:          defaultAutoAck,
@@ -32,6 +47,16 @@ func newConsumerConfiguration() *ConsumerConfiguration {
 		noLocal:          defaultNoLocal,
 		noWait:           defaultNoWait,
 		arguments:        nil,
+		drainTimeout:     defaultDrainTimeout,
+		failureOutcome:   defaultFailureOutcome,
+		panicOutcome:     defaultPanicOutcome,
+		maxDeliveries:    0,
+		codecs:           defaultCodecs,
+		concurrency:      defaultConcurrency,
+		metrics:          nil,
+		partitionKey:     nil,
+		middlewares:      nil,
+		bindings:         nil,
 	}
 }
 
@@ -51,23 +76,40 @@ func (config *ConsumerConfiguration) getKey(queue *amqp.Queue) string {
 	return queue.Name
 }
 
-func (config *ConsumerConfiguration) bindQueueToExchange(channel *amqp.Channel, queue *amqp.Queue, args amqp.Table) {
-	if config.ExchangeConfig == nil || config.QueueConfig == nil {
-		return
+func (config *ConsumerConfiguration) getConsumerTag() string {
+	if config.consumerIdentity != defaultConsumerIdentity {
+		return config.consumerIdentity
 	}
 
-	channel.QueueBind(
-		queue.Name,
-		config.routingKey,
-		config.ExchangeConfig.name,
-		config.ExchangeConfig.noWait,
-		args,
-	)
+	return uuid.New().String()
 }
 
-func (config *ConsumerConfiguration) configureQoS(channel *amqp.Channel, logger *logging.Logger) {
+// getBindings returns the binding implied by ExchangeConfig and RoutingKey,
+// if any, followed by the ones added with Bind.
+func (config *ConsumerConfiguration) getBindings() []*bindingConfiguration {
+	var bindings []*bindingConfiguration
+
+	if config.ExchangeConfig != nil && config.QueueConfig != nil {
+		binding := NewBindingConfiguration().
+			Exchange(config.ExchangeConfig.name).
+			RoutingKey(config.routingKey).
+			NoWait(config.ExchangeConfig.noWait).
+			AddArguments(config.arguments)
+
+		bindings = append(bindings, binding)
+	}
+
+	return append(bindings, config.bindings...)
+}
+
+func (config *ConsumerConfiguration) configureQoS(channel *amqp.Channel, logger *logging.Logger) error {
 	if config.QosConfig == nil {
-		return
+		return nil
+	}
+
+	if config.QosConfig.prefetchCount > 0 && config.QosConfig.prefetchCount < config.concurrency {
+		logger.Standard.Info().Int("prefetch-count", config.concurrency).Msg("Raising prefetch count to match consumer concurrency")
+		config.QosConfig.prefetchCount = config.concurrency
 	}
 
 	err := channel.Qos(
@@ -76,7 +118,7 @@ func (config *ConsumerConfiguration) configureQoS(channel *amqp.Channel, logger
 		config.QosConfig.global,
 	)
 
-	failOnError(logger, err, "Failed to set QoS")
+	return handleError(logger, ErrConsume, err, "Failed to set QoS")
 }
 
 func (config *ConsumerConfiguration) ConsumerIdentity(identity string) *ConsumerConfiguration {
@@ -84,6 +126,14 @@ func (config *ConsumerConfiguration) ConsumerIdentity(identity string) *Consumer
 	return config
 }
 
+// Bind adds a binding on top of the one implied by ExchangeConfig and
+// RoutingKey. Call it once per exchange or routing key the queue should
+// receive from.
+func (config *ConsumerConfiguration) Bind(binding *bindingConfiguration) *ConsumerConfiguration {
+	config.bindings = append(config.bindings, binding)
+	return config
+}
+
 func (config *ConsumerConfiguration) RoutingKey(routingKey string) *ConsumerConfiguration {
 	config.routingKey = routingKey
 	return config
@@ -113,3 +163,59 @@ func (config *ConsumerConfiguration) AddArguments(args *Arguments) *ConsumerConf
 	config.arguments = args
 	return config
 }
+
+func (config *ConsumerConfiguration) DrainTimeout(timeout time.Duration) *ConsumerConfiguration {
+	config.drainTimeout = timeout
+	return config
+}
+
+// FailureOutcome settles failed deliveries. An outcome other than the
+// declared constants is ignored.
+func (config *ConsumerConfiguration) FailureOutcome(outcome Outcome) *ConsumerConfiguration {
+	if outcome.valid() {
+		config.failureOutcome = outcome
+	}
+	return config
+}
+
+// PanicOutcome settles deliveries whose handler panicked. An outcome other
+// than the declared constants falls back to FailureOutcome.
+func (config *ConsumerConfiguration) PanicOutcome(outcome Outcome) *ConsumerConfiguration {
+	config.panicOutcome = outcome
+	return config
+}
+
+func (config *ConsumerConfiguration) MaxDeliveries(maxDeliveries int64) *ConsumerConfiguration {
+	config.maxDeliveries = maxDeliveries
+	return config
+}
+
+func (config *ConsumerConfiguration) Codecs(registry *CodecRegistry) *ConsumerConfiguration {
+	config.codecs = registry
+	return config
+}
+
+func (config *ConsumerConfiguration) Concurrency(workers int) *ConsumerConfiguration {
+	if workers < 1 {
+		workers = 1
+	}
+	config.concurrency = workers
+	return config
+}
+
+func (config *ConsumerConfiguration) WorkerMetrics(metrics *WorkerMetrics) *ConsumerConfiguration {
+	config.metrics = metrics
+	return config
+}
+
+// PartitionBy keeps deliveries sharing a key in order while Concurrency workers
+// process different keys in parallel.
+func (config *ConsumerConfiguration) PartitionBy(partitionKey PartitionKey) *ConsumerConfiguration {
+	config.partitionKey = partitionKey
+	return config
+}
+
+func (config *ConsumerConfiguration) Use(middlewares ...Middleware) *ConsumerConfiguration {
+	config.middlewares = append(config.middlewares, middlewares...)
+	return config
+}
@@ -2,6 +2,7 @@ package messaging
 
 import (
 	"context"
+	"sync"
 
 	logging "github.com/mitz-it/golang-logging"
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -11,73 +12,161 @@ type OnMessageReceived func(ctx context.Context, message []byte)
 
 type IConsumer interface {
 	Consume(configure ConfigureConsumer, onMessageReceived OnMessageReceived)
+	TryConsume(ctx context.Context, configure ConfigureConsumer, onMessageReceived OnMessageReceived) error
+	ConsumeWithHandler(ctx context.Context, configure ConfigureConsumer, handler Handler) error
+	Bind(ctx context.Context, queue string, binding *bindingConfiguration) error
+	Unbind(ctx context.Context, queue string, binding *bindingConfiguration) error
+	Rebind(ctx context.Context, queue string, from *bindingConfiguration, to *bindingConfiguration) error
+	Close(ctx context.Context) error
 }
 
 type Consumer struct {
 	connectionString string
-	connection       *amqp.Connection
-	channel          *amqp.Channel
+	connection       *connection
 	logger           *logging.Logger
+	mutex            sync.Mutex
+	closed           bool
+	subscriptions    map[*subscription]context.CancelFunc
+	active           sync.WaitGroup
 }
 
+// Deprecated: use TryConsume, which returns the error instead of panicking and stops when its context is cancelled.
 func (consumer *Consumer) Consume(configure ConfigureConsumer, onMessageReceived OnMessageReceived) {
+	err := consumer.TryConsume(context.Background(), configure, onMessageReceived)
+	failOnError(consumer.logger, err, "Failed to consume messages")
+}
+
+func (consumer *Consumer) TryConsume(ctx context.Context, configure ConfigureConsumer, onMessageReceived OnMessageReceived) error {
+	return consumer.ConsumeWithHandler(ctx, configure, toHandler(onMessageReceived))
+}
+
+func (consumer *Consumer) ConsumeWithHandler(ctx context.Context, configure ConfigureConsumer, handler Handler) error {
 	config := configureConsumer(configure)
 
-	declareExchange(consumer.logger, consumer.channel, config.ExchangeConfig)
+	sub, ctx, err := consumer.subscribe(ctx, config, handler)
+
+	if err != nil {
+		return err
+	}
+
+	defer consumer.unsubscribe(sub)
+	defer sub.publish.close()
+
+	messages, err := sub.start(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	for {
+		err := sub.run(ctx, messages)
+		sub.stop()
 
-	queue := declareQueue(consumer.logger, consumer.channel, config.QueueConfig)
+		if err == nil {
+			return nil
+		}
 
-	args := config.toArgumentsTable()
+		if messages, err = sub.recover(ctx); err != nil {
+			return err
+		}
 
-	config.bindQueueToExchange(
-		consumer.channel,
-		queue,
-		args,
-	)
+		if messages == nil {
+			return nil
+		}
+	}
+}
 
-	config.configureQoS(consumer.channel, consumer.logger)
+func (consumer *Consumer) subscribe(ctx context.Context, config *ConsumerConfiguration, handler Handler) (*subscription, context.Context, error) {
+	consumer.mutex.Lock()
+	defer consumer.mutex.Unlock()
 
-	key := config.getKey(queue)
+	if consumer.closed {
+		return nil, nil, newError(ErrClosed, amqp.ErrClosed, "Consumer is closed")
+	}
 
-	messages, err := consumer.channel.Consume(
-		key,
-		config.consumerIdentity,
-		config.autoAck,
-		config.exclusive,
-		config.noLocal,
-		config.noWait,
-		args,
-	)
+	ctx, cancel := context.WithCancel(ctx)
 
-	failOnError(consumer.logger, err, "Failed to register a consumer")
+	middlewares := append(append([]Middleware{}, consumer.connection.config.middlewares...), config.middlewares...)
 
-	var forever chan struct{}
+	sub := &subscription{
+		consumer: consumer,
+		config:   config,
+		handler:  chainMiddlewares(handler, middlewares...),
+		bindings: config.getBindings(),
+		publish:  newRepublisher(consumer.connection, consumer.logger),
+	}
 
-	go consumer.handleMessages(messages, onMessageReceived, config.autoAck, config, key)
+	consumer.subscriptions[sub] = cancel
+	consumer.active.Add(1)
 
-	consumer.logger.Standard.Info().Msg("Waiting for messages")
-	<-forever
+	return sub, ctx, nil
 }
 
-func (consumer *Consumer) handleMessages(messages <-chan amqp.Delivery, onMessageReceived OnMessageReceived, autoAck bool, config *ConsumerConfiguration, key string) {
-	for message := range messages {
-		ctx := consumer.createConsumeContext(context.Background(), config, message, key)
-		onMessageReceived(ctx, message.Body)
-		if !autoAck {
-			message.Ack(false)
-		}
+func (consumer *Consumer) unsubscribe(sub *subscription) {
+	consumer.mutex.Lock()
+	defer consumer.mutex.Unlock()
+
+	if cancel, ok := consumer.subscriptions[sub]; ok {
+		cancel()
+		delete(consumer.subscriptions, sub)
+		consumer.active.Done()
 	}
 }
 
-func NewConsumer(logger *logging.Logger, connectionString string) IConsumer {
+func (consumer *Consumer) Close(ctx context.Context) error {
+	consumer.mutex.Lock()
+
+	if consumer.closed {
+		consumer.mutex.Unlock()
+		return nil
+	}
+
+	consumer.closed = true
+
+	for _, cancel := range consumer.subscriptions {
+		cancel()
+	}
+
+	consumer.mutex.Unlock()
+
+	drained := make(chan struct{})
+
+	go func() {
+		consumer.active.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		consumer.logger.Standard.Warn().Msg("Consumer: Closing before all subscriptions were drained")
+	}
+
+	return consumer.connection.close(ctx)
+}
+
+// Deprecated: use TryNewConsumer, which returns the connection error instead of panicking.
+func NewConsumer(logger *logging.Logger, connectionString string, configure ...ConfigureClient) IConsumer {
+	consumer, err := TryNewConsumer(logger, connectionString, configure...)
+
+	failOnError(logger, err, "Failed to create consumer")
+
+	return consumer
+}
+
+func TryNewConsumer(logger *logging.Logger, connectionString string, configure ...ConfigureClient) (IConsumer, error) {
+	connection, err := dial(logger, connectionString, configureClient(configure))
+
+	if err != nil {
+		return nil, err
+	}
+
 	consumer := &Consumer{
 		connectionString: connectionString,
-		connection:       new(amqp.Connection),
-		channel:          new(amqp.Channel),
+		connection:       connection,
 		logger:           logger,
+		subscriptions:    make(map[*subscription]context.CancelFunc),
 	}
 
-	consumer.connect()
-
-	return consumer
+	return consumer, nil
 }
@@ -0,0 +1,221 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	logging "github.com/mitz-it/golang-logging"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const parkingReasonHeader string = "x-parking-reason"
+const parkedFromHeader string = "x-parked-from"
+const parkedAtHeader string = "x-parked-at"
+const deliveryCountHeader string = "x-delivery-count"
+const deliveryLimitArgument string = "x-delivery-limit"
+
+type deadLetterConfiguration struct {
+	exchange   string
+	queue      string
+	routingKey string
+	parkingLot string
+}
+
+func NewDeadLetterConfiguration() *deadLetterConfiguration {
+	return &deadLetterConfiguration{
+		exchange:   "",
+		queue:      "",
+		routingKey: "",
+		parkingLot: "",
+	}
+}
+
+func (config *deadLetterConfiguration) exchangeName(queue string) string {
+	return valueOrDefault(config.exchange, queue+".dlx")
+}
+
+func (config *deadLetterConfiguration) queueName(queue string) string {
+	return valueOrDefault(config.queue, queue+".dlq")
+}
+
+func (config *deadLetterConfiguration) routingKeyFor(queue string) string {
+	return valueOrDefault(config.routingKey, queue)
+}
+
+func (config *deadLetterConfiguration) parkingLotName(queue string) string {
+	return valueOrDefault(config.parkingLot, queue+".parking-lot")
+}
+
+func valueOrDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// declareDeadLetterTopology declares the dead-letter exchange, the dead-letter
+// queue bound to it and the parking lot queue, and returns the arguments the
+// main queue needs to dead-letter into them.
+func declareDeadLetterTopology(logger *logging.Logger, channel *amqp.Channel, config *queueConfiguration) (Arguments, error) {
+	deadLetter := config.deadLetter
+
+	if config.name == "" {
+		return nil, handleError(logger, ErrDeclaration, errors.New("dead-lettering requires a named queue"), "Failed to declare dead-letter topology")
+	}
+
+	exchange := NewExchangeConfiguration().
+		Name(deadLetter.exchangeName(config.name)).
+		Kind(Direct).
+		Durable(config.durable)
+
+	if err := declareExchange(logger, channel, exchange); err != nil {
+		return nil, err
+	}
+
+	for _, name := range []string{deadLetter.queueName(config.name), deadLetter.parkingLotName(config.name)} {
+		queue := NewQueueConfiguration().Name(name).Durable(config.durable)
+
+		if _, err := declareQueue(logger, channel, queue); err != nil {
+			return nil, err
+		}
+	}
+
+	err := channel.QueueBind(
+		deadLetter.queueName(config.name),
+		deadLetter.routingKeyFor(config.name),
+		deadLetter.exchangeName(config.name),
+		false,
+		nil,
+	)
+
+	if err != nil {
+		return nil, handleError(logger, ErrDeclaration, err, "Failed to bind dead-letter queue")
+	}
+
+	args := Arguments{}
+
+	if config.arguments != nil {
+		for key, value := range *config.arguments {
+			args[key] = value
+		}
+	}
+
+	args["x-dead-letter-exchange"] = deadLetter.exchangeName(config.name)
+	args["x-dead-letter-routing-key"] = deadLetter.routingKeyFor(config.name)
+
+	return args, nil
+}
+
+// deliveryCount derives how many times a delivery has been handed to a
+// consumer, from the quorum queue x-delivery-count header or the x-death
+// history, whichever is higher. Expirations from the retry queues are counted
+// as deliveries too: each stands for an attempt that failed and was acked
+// once its copy had been republished, which nothing else records.
+func deliveryCount(message amqp.Delivery, deaths []Death) int64 {
+	var previous int64
+
+	switch count := message.Headers[deliveryCountHeader].(type) {
+	case int32:
+		previous = int64(count)
+	case int64:
+		previous = count
+	}
+
+	var died int64
+
+	for _, death := range deaths {
+		died += death.Count
+	}
+
+	if died > previous {
+		previous = died
+	}
+
+	return previous + 1
+}
+
+func (config *ConsumerConfiguration) getMaxDeliveries() int64 {
+	if config.maxDeliveries > 0 || config.QueueConfig == nil || config.QueueConfig.arguments == nil {
+		return config.maxDeliveries
+	}
+
+	switch limit := (*config.QueueConfig.arguments)[deliveryLimitArgument].(type) {
+	case int:
+		return int64(limit)
+	case int32:
+		return int64(limit)
+	case int64:
+		return limit
+	default:
+		return 0
+	}
+}
+
+func (sub *subscription) canPark() bool {
+	return sub.config.QueueConfig != nil && sub.config.QueueConfig.deadLetter != nil
+}
+
+func (sub *subscription) exceedsDeliveryLimit(message amqp.Delivery) (bool, int64) {
+	limit := sub.config.getMaxDeliveries()
+
+	if limit <= 0 {
+		return false, 0
+	}
+
+	count := deliveryCount(message, parseDeaths(message.Headers))
+
+	return count > limit, count
+}
+
+// park moves a poison message to the parking lot queue, recording why it was
+// parked, so it stops cycling between the queue and its dead-letter exchange.
+func (sub *subscription) park(ctx context.Context, key string, message amqp.Delivery, reason string) Outcome {
+	parkingLot := sub.config.QueueConfig.deadLetter.parkingLotName(key)
+
+	publishing := toPublishing(message)
+	publishing.Headers[parkingReasonHeader] = reason
+	publishing.Headers[parkedFromHeader] = key
+	publishing.Headers[parkedAtHeader] = time.Now().UTC()
+
+	err := sub.publish.publish(ctx, emptyExchangeName, parkingLot, publishing)
+
+	if err != nil {
+		handleError(sub.consumer.logger, ErrPublish, err, "Consumer: Failed to park message")
+		return NackRequeue
+	}
+
+	sub.consumer.logger.Standard.Warn().Str("message-id", message.MessageId).Str("reason", reason).Msg("Consumer: Message parked")
+
+	return Ack
+}
+
+func deliveryLimitReason(count int64) string {
+	return fmt.Sprintf("delivery limit exceeded after %d deliveries", count)
+}
+
+func (config *queueConfiguration) DeadLetter(deadLetter *deadLetterConfiguration) *queueConfiguration {
+	config.deadLetter = deadLetter
+	return config
+}
+
+func (config *deadLetterConfiguration) Exchange(name string) *deadLetterConfiguration {
+	config.exchange = name
+	return config
+}
+
+func (config *deadLetterConfiguration) Queue(name string) *deadLetterConfiguration {
+	config.queue = name
+	return config
+}
+
+func (config *deadLetterConfiguration) RoutingKey(routingKey string) *deadLetterConfiguration {
+	config.routingKey = routingKey
+	return config
+}
+
+func (config *deadLetterConfiguration) ParkingLot(name string) *deadLetterConfiguration {
+	config.parkingLot = name
+	return config
+}
@@ -0,0 +1,107 @@
+package messaging
+
+import (
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func deathEntry(queue string, reason string, count int64) amqp.Table {
+	return amqp.Table{"queue": queue, "reason": reason, "exchange": "", "count": count}
+}
+
+func TestDeliveryCount(t *testing.T) {
+	cases := []struct {
+		name     string
+		headers  amqp.Table
+		expected int64
+	}{
+		{"first delivery", nil, 1},
+		{"int32 delivery count", amqp.Table{deliveryCountHeader: int32(2)}, 3},
+		{"int64 delivery count", amqp.Table{deliveryCountHeader: int64(4)}, 5},
+		{"string delivery count", amqp.Table{deliveryCountHeader: "4"}, 1},
+		{"rejected", amqp.Table{"x-death": []interface{}{deathEntry("orders", "rejected", 2)}}, 3},
+		{"retry expirations", amqp.Table{"x-death": []interface{}{
+			deathEntry("orders.retry.1s", "expired", 1),
+			deathEntry("orders.retry.2s", "expired", 1),
+		}}, 3},
+		{"rejected and retried", amqp.Table{"x-death": []interface{}{
+			deathEntry("orders", "rejected", 1),
+			deathEntry("orders.retry.1s", "expired", 2),
+		}}, 4},
+		{"delivery count higher", amqp.Table{
+			deliveryCountHeader: int64(5),
+			"x-death":           []interface{}{deathEntry("orders.retry.1s", "expired", 2)},
+		}, 6},
+		{"deaths higher", amqp.Table{
+			deliveryCountHeader: int64(1),
+			"x-death":           []interface{}{deathEntry("orders.retry.1s", "expired", 3)},
+		}, 4},
+	}
+
+	for _, c := range cases {
+		message := amqp.Delivery{Headers: c.headers}
+
+		if count := deliveryCount(message, parseDeaths(c.headers)); count != c.expected {
+			t.Errorf("%s: expected %d, got %d", c.name, c.expected, count)
+		}
+	}
+}
+
+func TestGetMaxDeliveries(t *testing.T) {
+	withLimit := func(limit interface{}) *ConsumerConfiguration {
+		config := newConsumerConfiguration()
+		config.QueueConfig = NewQueueConfiguration().Name("orders").AddArguments(&Arguments{deliveryLimitArgument: limit})
+		return config
+	}
+
+	cases := []struct {
+		name     string
+		config   *ConsumerConfiguration
+		expected int64
+	}{
+		{"unset", newConsumerConfiguration(), 0},
+		{"max deliveries", newConsumerConfiguration().MaxDeliveries(3), 3},
+		{"int limit", withLimit(4), 4},
+		{"int32 limit", withLimit(int32(5)), 5},
+		{"int64 limit", withLimit(int64(6)), 6},
+		{"string limit", withLimit("7"), 0},
+		{"max deliveries wins", withLimit(int64(6)).MaxDeliveries(2), 2},
+		{"queue without arguments", func() *ConsumerConfiguration {
+			config := newConsumerConfiguration()
+			config.QueueConfig = NewQueueConfiguration().Name("orders")
+			return config
+		}(), 0},
+	}
+
+	for _, c := range cases {
+		if limit := c.config.getMaxDeliveries(); limit != c.expected {
+			t.Errorf("%s: expected %d, got %d", c.name, c.expected, limit)
+		}
+	}
+}
+
+func TestExceedsDeliveryLimit(t *testing.T) {
+	sub := &subscription{config: newConsumerConfiguration().MaxDeliveries(3)}
+
+	cases := []struct {
+		retried  int64
+		exceeded bool
+	}{
+		{0, false},
+		{2, false},
+		{3, true},
+	}
+
+	for _, c := range cases {
+		headers := amqp.Table{"x-death": []interface{}{deathEntry("orders.retry.1s", "expired", c.retried)}}
+
+		if exceeded, count := sub.exceedsDeliveryLimit(amqp.Delivery{Headers: headers}); exceeded != c.exceeded {
+			t.Errorf("%d retries: expected exceeded %v, got %v at delivery %d", c.retried, c.exceeded, exceeded, count)
+		}
+	}
+
+	if exceeded, _ := (&subscription{config: newConsumerConfiguration()}).exceedsDeliveryLimit(amqp.Delivery{}); exceeded {
+		t.Fatal("expected no limit without MaxDeliveries or x-delivery-limit")
+	}
+}
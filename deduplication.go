@@ -0,0 +1,187 @@
+package messaging
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DeduplicationStore claims message ids for processing. Begin returns
+// ErrDuplicate for a message that was already processed and ErrInFlight for
+// one that is being processed right now.
+type DeduplicationStore interface {
+	Begin(ctx context.Context, messageId string) (DeduplicationEntry, error)
+}
+
+// DeduplicationEntry is a claimed message id. The handler runs with Context,
+// then Commit records the message as processed, or Rollback releases the claim
+// so a redelivery is processed again.
+type DeduplicationEntry interface {
+	Context() context.Context
+	Commit() error
+	Rollback() error
+}
+
+// DeduplicationMiddleware skips, and acks, deliveries whose MessageId was
+// already processed successfully. Deliveries without a MessageId always reach
+// the handler.
+func DeduplicationMiddleware(store DeduplicationStore) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, message *Message) error {
+			if message.MessageId == "" {
+				return next(ctx, message)
+			}
+
+			entry, err := store.Begin(ctx, message.MessageId)
+
+			if errors.Is(err, ErrDuplicate) {
+				return nil
+			}
+
+			if errors.Is(err, ErrInFlight) {
+				return WithOutcome(err, NackRequeue)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			settled := false
+
+			// A panicking handler must still release the claim, otherwise
+			// redeliveries stay ErrInFlight and a SQL store keeps its
+			// transaction open.
+			defer func() {
+				if !settled {
+					entry.Rollback()
+				}
+			}()
+
+			if err := next(entry.Context(), message); err != nil {
+				return err
+			}
+
+			settled = true
+
+			return entry.Commit()
+		}
+	}
+}
+
+type memoryDeduplicationEntry struct {
+	store     *MemoryDeduplicationStore
+	ctx       context.Context
+	messageId string
+}
+
+type memoryRecord struct {
+	messageId  string
+	processing bool
+	expiresAt  time.Time
+}
+
+// MemoryDeduplicationStore remembers up to capacity processed message ids for
+// ttl each, evicting the least recently processed first.
+type MemoryDeduplicationStore struct {
+	mutex    sync.Mutex
+	capacity int
+	ttl      time.Duration
+	records  map[string]*list.Element
+	recency  *list.List
+}
+
+func NewMemoryDeduplicationStore(capacity int, ttl time.Duration) *MemoryDeduplicationStore {
+	return &MemoryDeduplicationStore{
+		capacity: capacity,
+		ttl:      ttl,
+		records:  make(map[string]*list.Element),
+		recency:  list.New(),
+	}
+}
+
+func (store *MemoryDeduplicationStore) Begin(ctx context.Context, messageId string) (DeduplicationEntry, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if element, ok := store.records[messageId]; ok {
+		record := element.Value.(*memoryRecord)
+
+		if record.processing {
+			return nil, ErrInFlight
+		}
+
+		if time.Now().Before(record.expiresAt) {
+			return nil, ErrDuplicate
+		}
+
+		store.remove(element)
+	}
+
+	store.records[messageId] = store.recency.PushFront(&memoryRecord{messageId: messageId, processing: true})
+
+	return &memoryDeduplicationEntry{store: store, ctx: ctx, messageId: messageId}, nil
+}
+
+func (store *MemoryDeduplicationStore) remove(element *list.Element) {
+	store.recency.Remove(element)
+	delete(store.records, element.Value.(*memoryRecord).messageId)
+}
+
+// evict drops records from the back of the recency list, which holds the
+// oldest ones since Commit moves a record to the front. In-flight records are
+// stepped over without being evicted.
+func (store *MemoryDeduplicationStore) evict() {
+	now := time.Now()
+
+	for element := store.recency.Back(); element != nil; {
+		previous := element.Prev()
+		record := element.Value.(*memoryRecord)
+
+		if !record.processing {
+			if store.recency.Len() <= store.capacity && now.Before(record.expiresAt) {
+				return
+			}
+
+			store.remove(element)
+		}
+
+		element = previous
+	}
+}
+
+func (entry *memoryDeduplicationEntry) Context() context.Context {
+	return entry.ctx
+}
+
+func (entry *memoryDeduplicationEntry) Commit() error {
+	store := entry.store
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if element, ok := store.records[entry.messageId]; ok {
+		record := element.Value.(*memoryRecord)
+		record.processing = false
+		record.expiresAt = time.Now().Add(store.ttl)
+		store.recency.MoveToFront(element)
+	}
+
+	store.evict()
+
+	return nil
+}
+
+func (entry *memoryDeduplicationEntry) Rollback() error {
+	store := entry.store
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if element, ok := store.records[entry.messageId]; ok {
+		store.remove(element)
+	}
+
+	return nil
+}
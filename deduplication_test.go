@@ -0,0 +1,237 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func commit(t *testing.T, store DeduplicationStore, messageId string) {
+	t.Helper()
+
+	entry, err := store.Begin(context.Background(), messageId)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := entry.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoryStoreEvictsLeastRecentlyProcessed(t *testing.T) {
+	store := NewMemoryDeduplicationStore(2, time.Hour)
+
+	commit(t, store, "a")
+	commit(t, store, "b")
+	commit(t, store, "c")
+
+	if _, err := store.Begin(context.Background(), "b"); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("expected b to be remembered, got %v", err)
+	}
+
+	if _, err := store.Begin(context.Background(), "c"); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("expected c to be remembered, got %v", err)
+	}
+
+	entry, err := store.Begin(context.Background(), "a")
+
+	if err != nil {
+		t.Fatalf("expected a to be evicted over capacity, got %v", err)
+	}
+
+	entry.Rollback()
+}
+
+func TestMemoryStoreKeepsClaimsInFlightOverCapacity(t *testing.T) {
+	store := NewMemoryDeduplicationStore(1, time.Hour)
+
+	entry, err := store.Begin(context.Background(), "processing")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commit(t, store, "a")
+	commit(t, store, "b")
+
+	if _, err := store.Begin(context.Background(), "processing"); !errors.Is(err, ErrInFlight) {
+		t.Fatalf("expected the in-flight claim to survive eviction, got %v", err)
+	}
+
+	entry.Rollback()
+}
+
+func TestMemoryStoreExpiresAfterTTL(t *testing.T) {
+	store := NewMemoryDeduplicationStore(10, time.Hour)
+
+	commit(t, store, "a")
+
+	store.records["a"].Value.(*memoryRecord).expiresAt = time.Now().Add(-time.Second)
+
+	entry, err := store.Begin(context.Background(), "a")
+
+	if err != nil {
+		t.Fatalf("expected an expired id to be claimed again, got %v", err)
+	}
+
+	entry.Rollback()
+}
+
+func TestMemoryStoreEvictsExpiredOnCommit(t *testing.T) {
+	store := NewMemoryDeduplicationStore(10, time.Hour)
+
+	commit(t, store, "a")
+
+	store.records["a"].Value.(*memoryRecord).expiresAt = time.Now().Add(-time.Second)
+
+	commit(t, store, "b")
+
+	if _, ok := store.records["a"]; ok || store.recency.Len() != 1 {
+		t.Fatalf("expected the expired record to be evicted, %d records left", store.recency.Len())
+	}
+}
+
+func TestMemoryStoreRollbackReleasesClaim(t *testing.T) {
+	store := NewMemoryDeduplicationStore(10, time.Hour)
+
+	entry, err := store.Begin(context.Background(), "a")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Begin(context.Background(), "a"); !errors.Is(err, ErrInFlight) {
+		t.Fatalf("expected ErrInFlight while processing, got %v", err)
+	}
+
+	entry.Rollback()
+
+	if entry, err = store.Begin(context.Background(), "a"); err != nil {
+		t.Fatalf("expected the claim to be released, got %v", err)
+	}
+
+	entry.Rollback()
+}
+
+func TestDeduplicationMiddlewareAcksDuplicates(t *testing.T) {
+	store := NewMemoryDeduplicationStore(10, time.Hour)
+	calls := 0
+	handler := DeduplicationMiddleware(store)(func(ctx context.Context, message *Message) error {
+		calls++
+		return nil
+	})
+
+	message := &Message{Metadata: Metadata{MessageId: "a"}}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if err := handler(context.Background(), message); outcomeOf(err, NackRequeue) != Ack {
+			t.Fatalf("expected delivery %d to be acked, got %v", attempt, err)
+		}
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected the handler to run once, ran %d times", calls)
+	}
+}
+
+func TestDeduplicationMiddlewareRequeuesInFlight(t *testing.T) {
+	store := NewMemoryDeduplicationStore(10, time.Hour)
+	middleware := DeduplicationMiddleware(store)
+	message := &Message{Metadata: Metadata{MessageId: "a"}}
+	var redelivery error
+
+	handler := middleware(func(ctx context.Context, message *Message) error {
+		redelivery = middleware(func(ctx context.Context, message *Message) error {
+			t.Fatal("expected the concurrent redelivery to skip the handler")
+			return nil
+		})(ctx, message)
+
+		return nil
+	})
+
+	if err := handler(context.Background(), message); err != nil {
+		t.Fatal(err)
+	}
+
+	if !errors.Is(redelivery, ErrInFlight) || outcomeOf(redelivery, Reject) != NackRequeue {
+		t.Fatalf("expected the in-flight redelivery to be requeued, got %v", redelivery)
+	}
+}
+
+func TestDeduplicationMiddlewareRetriesFailedMessages(t *testing.T) {
+	store := NewMemoryDeduplicationStore(10, time.Hour)
+	failure := errors.New("handler failed")
+	calls := 0
+	handler := DeduplicationMiddleware(store)(func(ctx context.Context, message *Message) error {
+		calls++
+		if calls == 1 {
+			return failure
+		}
+		return nil
+	})
+
+	message := &Message{Metadata: Metadata{MessageId: "a"}}
+
+	if err := handler(context.Background(), message); !errors.Is(err, failure) {
+		t.Fatalf("expected the handler error, got %v", err)
+	}
+
+	if err := handler(context.Background(), message); err != nil || calls != 2 {
+		t.Fatalf("expected the redelivery to be processed, got %v after %d calls", err, calls)
+	}
+}
+
+func TestDeduplicationMiddlewareReleasesClaimOnPanic(t *testing.T) {
+	store := NewMemoryDeduplicationStore(10, time.Hour)
+	message := &Message{Metadata: Metadata{MessageId: "a"}}
+	handler := DeduplicationMiddleware(store)(func(ctx context.Context, message *Message) error {
+		panic("handler exploded")
+	})
+
+	func() {
+		defer func() {
+			if recovered := recover(); recovered != "handler exploded" {
+				t.Fatalf("expected the panic to propagate, got %v", recovered)
+			}
+		}()
+
+		handler(context.Background(), message)
+	}()
+
+	entry, err := store.Begin(context.Background(), "a")
+
+	if err != nil {
+		t.Fatalf("expected the claim to be released after the panic, got %v", err)
+	}
+
+	entry.Rollback()
+}
+
+func TestMemoryStoreEvictsOnlyFromTheBack(t *testing.T) {
+	store := NewMemoryDeduplicationStore(3, time.Hour)
+
+	inFlight, err := store.Begin(context.Background(), "processing")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commit(t, store, "a")
+	commit(t, store, "b")
+	commit(t, store, "c")
+
+	if _, ok := store.records["a"]; ok {
+		t.Fatal("expected the oldest record to be evicted")
+	}
+
+	for _, messageId := range []string{"processing", "b", "c"} {
+		if _, ok := store.records[messageId]; !ok {
+			t.Fatalf("expected %s to be kept", messageId)
+		}
+	}
+
+	inFlight.Rollback()
+}
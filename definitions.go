@@ -0,0 +1,204 @@
+package messaging
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+)
+
+const defaultVhost string = "/"
+
+// Definitions mirrors the exchanges, queues and bindings sections of the
+// RabbitMQ management definitions.json. Users, vhosts, permissions and
+// policies are not carried over.
+type Definitions struct {
+	RabbitVersion string                `json:"rabbit_version,omitempty"`
+	Queues        []DefinitionsQueue    `json:"queues"`
+	Exchanges     []DefinitionsExchange `json:"exchanges"`
+	Bindings      []DefinitionsBinding  `json:"bindings"`
+}
+
+type DefinitionsQueue struct {
+	Name       string    `json:"name"`
+	Vhost      string    `json:"vhost"`
+	Durable    bool      `json:"durable"`
+	AutoDelete bool      `json:"auto_delete"`
+	Arguments  Arguments `json:"arguments"`
+}
+
+type DefinitionsExchange struct {
+	Name       string    `json:"name"`
+	Vhost      string    `json:"vhost"`
+	Type       string    `json:"type"`
+	Durable    bool      `json:"durable"`
+	AutoDelete bool      `json:"auto_delete"`
+	Internal   bool      `json:"internal"`
+	Arguments  Arguments `json:"arguments"`
+}
+
+type DefinitionsBinding struct {
+	Source          string             `json:"source"`
+	Vhost           string             `json:"vhost"`
+	Destination     string             `json:"destination"`
+	DestinationType BindingDestination `json:"destination_type"`
+	RoutingKey      string             `json:"routing_key"`
+	Arguments       Arguments          `json:"arguments"`
+}
+
+func ParseDefinitions(data []byte) (*Definitions, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	definitions := &Definitions{}
+
+	if err := decoder.Decode(definitions); err != nil {
+		return nil, newError(ErrInvalidConfig, err, "Failed to parse definitions")
+	}
+
+	return definitions, nil
+}
+
+func LoadDefinitionsFile(path string) (*Definitions, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, newError(ErrInvalidConfig, err, "Failed to read definitions file")
+	}
+
+	return ParseDefinitions(data)
+}
+
+// NewDefinitions exports a topology for the given vhost, "/" when empty.
+// Exclusive queues are skipped since they only live as long as the connection
+// that declared them.
+func NewDefinitions(topology *Topology, vhost string) *Definitions {
+	vhost = valueOrDefault(vhost, defaultVhost)
+
+	definitions := &Definitions{
+		Queues:    []DefinitionsQueue{},
+		Exchanges: []DefinitionsExchange{},
+		Bindings:  []DefinitionsBinding{},
+	}
+
+	for _, exchange := range topology.Exchanges {
+		definitions.Exchanges = append(definitions.Exchanges, DefinitionsExchange{
+			Name:       exchange.Name,
+			Vhost:      vhost,
+			Type:       exchange.Kind,
+			Durable:    exchange.Durable,
+			AutoDelete: exchange.AutoDelete,
+			Internal:   exchange.Internal,
+			Arguments:  nonNilArguments(exchange.Arguments),
+		})
+	}
+
+	for _, queue := range topology.Queues {
+		if queue.Exclusive {
+			continue
+		}
+
+		definitions.Queues = append(definitions.Queues, DefinitionsQueue{
+			Name:       queue.Name,
+			Vhost:      vhost,
+			Durable:    queue.Durable,
+			AutoDelete: queue.AutoDelete,
+			Arguments:  nonNilArguments(queue.Arguments),
+		})
+	}
+
+	for _, binding := range topology.Bindings {
+		definitions.Bindings = append(definitions.Bindings, DefinitionsBinding{
+			Source:          binding.Source,
+			Vhost:           vhost,
+			Destination:     binding.Destination,
+			DestinationType: binding.destinationType(),
+			RoutingKey:      binding.RoutingKey,
+			Arguments:       nonNilArguments(binding.Arguments),
+		})
+	}
+
+	return definitions
+}
+
+func (definitions *Definitions) Marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(definitions, "", "  ")
+
+	if err != nil {
+		return nil, newError(ErrSerialization, err, "Failed to serialize definitions")
+	}
+
+	return data, nil
+}
+
+// Topology imports the entities of the given vhost, "/" when empty, and
+// validates them. Broker-provided exchanges (the default one and amq.*) are
+// left out, as the broker always declares them itself.
+func (definitions *Definitions) Topology(vhost string) (*Topology, error) {
+	vhost = valueOrDefault(vhost, defaultVhost)
+
+	topology := &Topology{}
+
+	for _, exchange := range definitions.Exchanges {
+		if exchange.Vhost != vhost || exchange.Name == "" || isPredeclaredExchange(exchange.Name) {
+			continue
+		}
+
+		topology.Exchanges = append(topology.Exchanges, ExchangeDefinition{
+			Name:       exchange.Name,
+			Kind:       exchange.Type,
+			Durable:    exchange.Durable,
+			AutoDelete: exchange.AutoDelete,
+			Internal:   exchange.Internal,
+			Arguments:  normalizeArguments(exchange.Arguments),
+		})
+	}
+
+	for _, queue := range definitions.Queues {
+		if queue.Vhost != vhost {
+			continue
+		}
+
+		topology.Queues = append(topology.Queues, QueueDefinition{
+			Name:       queue.Name,
+			Durable:    queue.Durable,
+			AutoDelete: queue.AutoDelete,
+			Arguments:  normalizeArguments(queue.Arguments),
+		})
+	}
+
+	for _, binding := range definitions.Bindings {
+		if binding.Vhost != vhost || binding.Source == "" {
+			continue
+		}
+
+		topology.Bindings = append(topology.Bindings, BindingDefinition{
+			Source:          binding.Source,
+			Destination:     binding.Destination,
+			DestinationType: binding.DestinationType,
+			RoutingKey:      binding.RoutingKey,
+			Arguments:       normalizeArguments(binding.Arguments),
+		})
+	}
+
+	if err := topology.Validate(); err != nil {
+		return nil, err
+	}
+
+	return topology, nil
+}
+
+func nonNilArguments(arguments Arguments) Arguments {
+	if arguments == nil {
+		return Arguments{}
+	}
+
+	return arguments
+}
+
+func normalizeArguments(arguments Arguments) Arguments {
+	if len(arguments) == 0 {
+		return nil
+	}
+
+	return *copyArguments(arguments)
+}
@@ -0,0 +1,134 @@
+package messaging
+
+import (
+	"reflect"
+	"testing"
+)
+
+const definitionsFixture = `{
+  "rabbit_version": "3.12.0",
+  "exchanges": [
+    {"name": "orders", "vhost": "/", "type": "topic", "durable": true, "auto_delete": false, "internal": false, "arguments": {}},
+    {"name": "orders.delayed", "vhost": "/", "type": "x-delayed-message", "durable": true, "auto_delete": false, "internal": false, "arguments": {"x-delayed-type": "topic"}},
+    {"name": "orders.sharded", "vhost": "/", "type": "x-consistent-hash", "durable": true, "auto_delete": false, "internal": false, "arguments": {}},
+    {"name": "amq.topic", "vhost": "/", "type": "topic", "durable": true, "auto_delete": false, "internal": false, "arguments": {}},
+    {"name": "billing", "vhost": "billing", "type": "direct", "durable": true, "auto_delete": false, "internal": false, "arguments": {}}
+  ],
+  "queues": [
+    {"name": "orders.created", "vhost": "/", "durable": true, "auto_delete": false, "arguments": {"x-message-ttl": 60000, "x-queue-type": "quorum", "x-delivery-limit": 5}},
+    {"name": "orders.shard", "vhost": "/", "durable": true, "auto_delete": false, "arguments": {}}
+  ],
+  "bindings": [
+    {"source": "orders.delayed", "vhost": "/", "destination": "orders.created", "destination_type": "queue", "routing_key": "orders.created", "arguments": {}},
+    {"source": "orders.sharded", "vhost": "/", "destination": "orders.shard", "destination_type": "queue", "routing_key": "10", "arguments": {}},
+    {"source": "amq.topic", "vhost": "/", "destination": "orders", "destination_type": "exchange", "routing_key": "orders.#", "arguments": {}},
+    {"source": "", "vhost": "/", "destination": "orders.created", "destination_type": "queue", "routing_key": "orders.created", "arguments": {}}
+  ]
+}`
+
+func importFixture(t *testing.T) *Topology {
+	t.Helper()
+
+	definitions, err := ParseDefinitions([]byte(definitionsFixture))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topology, err := definitions.Topology("")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return topology
+}
+
+func TestDefinitionsImportSkipsBrokerEntitiesAndOtherVhosts(t *testing.T) {
+	topology := importFixture(t)
+
+	names := []string{}
+
+	for _, exchange := range topology.Exchanges {
+		names = append(names, exchange.Name)
+	}
+
+	if !reflect.DeepEqual(names, []string{"orders", "orders.delayed", "orders.sharded"}) {
+		t.Fatalf("unexpected exchanges %v", names)
+	}
+
+	if len(topology.Queues) != 2 || len(topology.Bindings) != 3 {
+		t.Fatalf("expected 2 queues and 3 bindings, got %d and %d", len(topology.Queues), len(topology.Bindings))
+	}
+}
+
+func TestDefinitionsImportKeepsPluginExchangeKinds(t *testing.T) {
+	topology := importFixture(t)
+
+	for name, kind := range map[string]string{"orders.delayed": "x-delayed-message", "orders.sharded": "x-consistent-hash"} {
+		config := topology.Exchange(name)
+
+		if config == nil || config.kind != kind {
+			t.Fatalf("expected %s to keep kind %s, got %+v", name, kind, config)
+		}
+	}
+
+	if config := topology.Exchange("orders.delayed"); (*config.arguments)["x-delayed-type"] != "topic" {
+		t.Fatalf("expected plugin arguments to be kept, got %v", *config.arguments)
+	}
+}
+
+func TestDefinitionsImportConvertsNumbersToIntegers(t *testing.T) {
+	topology := importFixture(t)
+	arguments := topology.Queues[0].Arguments
+
+	for key, expected := range map[string]interface{}{"x-message-ttl": int64(60000), "x-delivery-limit": int64(5), "x-queue-type": "quorum"} {
+		if arguments[key] != expected {
+			t.Errorf("%s: expected %v (%T), got %v (%T)", key, expected, expected, arguments[key], arguments[key])
+		}
+	}
+}
+
+func TestDefinitionsRoundTrip(t *testing.T) {
+	topology := importFixture(t)
+
+	data, err := NewDefinitions(topology, "").Marshal()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	definitions, err := ParseDefinitions(data)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported, err := definitions.Topology("")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(imported, topology) {
+		t.Fatalf("expected the topology to survive export and import\nexported: %+v\nimported: %+v", topology, imported)
+	}
+}
+
+func TestAddExchangeKeepsPluginKind(t *testing.T) {
+	topology := (&Topology{}).AddExchange(NewExchangeConfiguration().Name("delayed").PluginKind("x-delayed-message"))
+
+	if err := topology.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if kind := topology.Exchanges[0].Kind; kind != "x-delayed-message" {
+		t.Fatalf("expected the plugin kind to be recorded, got %q", kind)
+	}
+
+	definitions := NewDefinitions(topology, "")
+
+	if kind := definitions.Exchanges[0].Type; kind != "x-delayed-message" {
+		t.Fatalf("expected the plugin kind to be exported, got %q", kind)
+	}
+}
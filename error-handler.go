@@ -2,6 +2,14 @@ package messaging
 
 import logging "github.com/mitz-it/golang-logging"
 
+func handleError(logger *logging.Logger, kind error, err error, message string) error {
+	if err == nil {
+		return nil
+	}
+	logger.Standard.Error().Err(err).Msg(message)
+	return newError(kind, err, message)
+}
+
 func failOnError(logger *logging.Logger, err error, message string) {
 	if err == nil {
 		return
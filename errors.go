@@ -0,0 +1,52 @@
+package messaging
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrConnection    = errors.New("messaging: connection failure")
+	ErrDeclaration   = errors.New("messaging: declaration failure")
+	ErrSerialization = errors.New("messaging: serialization failure")
+	ErrPublish       = errors.New("messaging: publish failure")
+	ErrConsume       = errors.New("messaging: consume failure")
+	ErrClosed        = errors.New("messaging: client closed")
+	ErrNacked        = errors.New("messaging: message nacked by broker")
+	ErrUnroutable    = errors.New("messaging: message returned as unroutable")
+	ErrVetoed        = errors.New("messaging: publish vetoed")
+	ErrDuplicate     = errors.New("messaging: duplicate message")
+	ErrInFlight      = errors.New("messaging: message is being processed")
+	ErrNoReply       = errors.New("messaging: no reply received")
+	ErrRemote        = errors.New("messaging: responder failed")
+	ErrInvalidConfig = errors.New("messaging: invalid configuration")
+)
+
+// Error carries one of the sentinel errors above as its Kind, so callers can
+// match it with errors.Is, while still exposing the underlying broker error
+// (e.g. *amqp.Error) through errors.As.
+type Error struct {
+	Kind    error
+	Message string
+	Err     error
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("%s: %s: %v", err.Kind, err.Message, err.Err)
+}
+
+func (err *Error) Is(target error) bool {
+	return target == err.Kind
+}
+
+func (err *Error) Unwrap() error {
+	return err.Err
+}
+
+func newError(kind error, err error, message string) error {
+	return &Error{
+		Kind:    kind,
+		Message: message,
+		Err:     err,
+	}
+}
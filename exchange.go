@@ -1,6 +1,9 @@
 package messaging
 
 import (
+	"fmt"
+	"strings"
+
 	logging "github.com/mitz-it/golang-logging"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
@@ -33,6 +36,40 @@ func parseKind(kind ExchangeKind) string {
 	return kind.ToString()
 }
 
+func ParseExchangeKind(kind string) (ExchangeKind, error) {
+	switch kind {
+	case "direct":
+		return Direct, nil
+	case "fanout":
+		return Fanout, nil
+	case "topic":
+		return Topic, nil
+	case "headers":
+		return Headers, nil
+	}
+
+	return Direct, newError(ErrInvalidConfig, fmt.Errorf("unknown exchange kind %q", kind), "Failed to parse exchange kind")
+}
+
+// isPluginExchangeKind reports whether kind names an exchange type provided by
+// a broker plugin, such as x-delayed-message or x-consistent-hash. These are
+// passed to the broker as they are.
+func isPluginExchangeKind(kind string) bool {
+	return strings.HasPrefix(kind, "x-") && len(kind) > len("x-")
+}
+
+// exchangeKindName keeps plugin kinds and normalizes anything else to a
+// built-in kind, falling back to direct as ParseExchangeKind does.
+func exchangeKindName(kind string) string {
+	if isPluginExchangeKind(kind) {
+		return kind
+	}
+
+	exchangeKind, _ := ParseExchangeKind(kind)
+
+	return exchangeKind.ToString()
+}
+
 func NewExchangeConfiguration() *exchangeConfiguration {
 	kind := parseKind(Fanout)
 	config := &exchangeConfiguration{
@@ -48,9 +85,13 @@ func NewExchangeConfiguration() *exchangeConfiguration {
 	return config
 }
 
-func declareExchange(logger *logging.Logger, channel *amqp.Channel, config *exchangeConfiguration) {
+func declareExchange(logger *logging.Logger, channel *amqp.Channel, config *exchangeConfiguration) error {
 	if config == nil {
-		return
+		return nil
+	}
+
+	if err := config.validate(); err != nil {
+		return handleError(logger, ErrInvalidConfig, err, "Invalid exchange arguments")
 	}
 
 	args := toArgumentsTable(config.arguments)
@@ -65,7 +106,7 @@ func declareExchange(logger *logging.Logger, channel *amqp.Channel, config *exch
 		args,
 	)
 
-	failOnError(logger, err, "Failed to declare exchange")
+	return handleError(logger, ErrDeclaration, err, "Failed to declare exchange")
 }
 
 func (config *exchangeConfiguration) Name(name string) *exchangeConfiguration {
@@ -79,6 +120,13 @@ func (config *exchangeConfiguration) Kind(exchangeKind ExchangeKind) *exchangeCo
 	return config
 }
 
+// PluginKind sets an exchange type provided by a broker plugin, e.g.
+// "x-delayed-message". The plugin must be enabled on the broker.
+func (config *exchangeConfiguration) PluginKind(kind string) *exchangeConfiguration {
+	config.kind = kind
+	return config
+}
+
 func (config *exchangeConfiguration) Durable(durable bool) *exchangeConfiguration {
 	config.durable = durable
 	return config
@@ -0,0 +1,104 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type GatherConfiguration struct {
+	count   int
+	until   func(replies []Reply) bool
+	timeout time.Duration
+}
+
+type ConfigureGather func(config *GatherConfiguration)
+
+// Reply is one response gathered by Requester.Gather. Responder is the AppId
+// the responder published its reply with.
+type Reply struct {
+	Responder string
+	Message   *Message
+	Err       error
+}
+
+func newGatherConfiguration() *GatherConfiguration {
+	return &GatherConfiguration{
+		count:   0,
+		until:   nil,
+		timeout: defaultGatherTimeout,
+	}
+}
+
+func (config *GatherConfiguration) done(replies []Reply) bool {
+	if config.count > 0 && len(replies) >= config.count {
+		return true
+	}
+
+	return config.until != nil && config.until(replies)
+}
+
+func (config *GatherConfiguration) buffer() int {
+	if config.count > 0 {
+		return config.count
+	}
+	return defaultGatherBuffer
+}
+
+// Gather publishes one request, typically to a fanout or topic exchange, and
+// collects replies until the configured count or predicate is satisfied or the
+// timeout elapses. Whatever was gathered by then is returned; ErrNoReply is
+// only reported when nobody answered.
+func (requester *Requester) Gather(ctx context.Context, messageEnvelop MessageEnvelop, configure ConfigureProducer, gather ConfigureGather) ([]Reply, error) {
+	config := newGatherConfiguration()
+
+	if gather != nil {
+		gather(config)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.timeout)
+	defer cancel()
+
+	messages, correlationId, err := requester.send(ctx, messageEnvelop, configure, config.buffer())
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer requester.forget(correlationId)
+
+	replies := []Reply{}
+
+	for !config.done(replies) {
+		select {
+		case message := <-messages:
+			replies = append(replies, Reply{
+				Responder: message.AppId,
+				Message:   message,
+				Err:       remoteError(message),
+			})
+		case <-ctx.Done():
+			if len(replies) == 0 {
+				return replies, handleError(requester.logger, ErrNoReply, errors.New("no responder answered"), "Requester: Gather timed out")
+			}
+			return replies, nil
+		}
+	}
+
+	return replies, nil
+}
+
+func (config *GatherConfiguration) Count(count int) *GatherConfiguration {
+	config.count = count
+	return config
+}
+
+func (config *GatherConfiguration) Until(predicate func(replies []Reply) bool) *GatherConfiguration {
+	config.until = predicate
+	return config
+}
+
+func (config *GatherConfiguration) Timeout(timeout time.Duration) *GatherConfiguration {
+	config.timeout = timeout
+	return config
+}
@@ -4,10 +4,15 @@ go 1.19
 
 require (
 	github.com/google/uuid v1.3.0
+	github.com/mattn/go-sqlite3 v1.14.17
 	github.com/mitz-it/golang-logging v0.0.1
 	github.com/rabbitmq/amqp091-go v1.5.0
+	github.com/rs/zerolog v1.28.0
+	github.com/vmihailenco/msgpack/v5 v5.3.5
 	go.opentelemetry.io/otel v1.10.0
 	go.opentelemetry.io/otel/trace v1.10.0
+	google.golang.org/protobuf v1.31.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -15,6 +20,6 @@ require (
 	github.com/go-logr/stdr v1.2.2 // indirect
 	github.com/mattn/go-colorable v0.1.12 // indirect
 	github.com/mattn/go-isatty v0.0.14 // indirect
-	github.com/rs/zerolog v1.28.0 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 	golang.org/x/sys v0.0.0-20210927094055-39ccf1dd6fa6 // indirect
 )
@@ -0,0 +1,75 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+type Handler func(ctx context.Context, message *Message) error
+
+type Outcome int
+
+const (
+	Ack Outcome = iota
+	NackRequeue
+	Reject
+	NackNoRequeue
+)
+
+func (outcome Outcome) ToString() string {
+	if !outcome.valid() {
+		return fmt.Sprintf("unknown(%d)", int(outcome))
+	}
+
+	outcomes := []string{"ack", "nack-requeue", "reject", "nack-no-requeue"}
+	return outcomes[outcome]
+}
+
+func (outcome Outcome) valid() bool {
+	return outcome >= Ack && outcome <= NackNoRequeue
+}
+
+type outcomeError struct {
+	outcome Outcome
+	err     error
+}
+
+func (err *outcomeError) Error() string {
+	if err.err == nil {
+		return fmt.Sprintf("messaging: handler requested %s", err.outcome.ToString())
+	}
+	return err.err.Error()
+}
+
+func (err *outcomeError) Unwrap() error {
+	return err.err
+}
+
+// WithOutcome lets a Handler choose how its delivery is settled instead of
+// falling back to the consumer's FailureOutcome. An outcome other than the
+// declared constants is ignored and FailureOutcome applies.
+func WithOutcome(err error, outcome Outcome) error {
+	return &outcomeError{outcome: outcome, err: err}
+}
+
+func outcomeOf(err error, failureOutcome Outcome) Outcome {
+	if err == nil {
+		return Ack
+	}
+
+	var outcomeErr *outcomeError
+
+	if errors.As(err, &outcomeErr) && outcomeErr.outcome.valid() {
+		return outcomeErr.outcome
+	}
+
+	return failureOutcome
+}
+
+func toHandler(onMessageReceived OnMessageReceived) Handler {
+	return func(ctx context.Context, message *Message) error {
+		onMessageReceived(ctx, message.Body)
+		return nil
+	}
+}
@@ -0,0 +1,104 @@
+package messaging
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// recordingAcknowledger records how a delivery was settled.
+type recordingAcknowledger struct {
+	settled string
+}
+
+func (acknowledger *recordingAcknowledger) Ack(tag uint64, multiple bool) error {
+	acknowledger.settled = Ack.ToString()
+	return nil
+}
+
+func (acknowledger *recordingAcknowledger) Nack(tag uint64, multiple bool, requeue bool) error {
+	if requeue {
+		acknowledger.settled = NackRequeue.ToString()
+	} else {
+		acknowledger.settled = NackNoRequeue.ToString()
+	}
+	return nil
+}
+
+func (acknowledger *recordingAcknowledger) Reject(tag uint64, requeue bool) error {
+	acknowledger.settled = Reject.ToString()
+	return nil
+}
+
+func TestOutcomeToString(t *testing.T) {
+	cases := []struct {
+		outcome  Outcome
+		expected string
+	}{
+		{Ack, "ack"},
+		{NackRequeue, "nack-requeue"},
+		{Reject, "reject"},
+		{NackNoRequeue, "nack-no-requeue"},
+		{Outcome(-1), "unknown(-1)"},
+		{Outcome(42), "unknown(42)"},
+	}
+
+	for _, c := range cases {
+		if name := c.outcome.ToString(); name != c.expected {
+			t.Errorf("expected %q, got %q", c.expected, name)
+		}
+	}
+}
+
+func TestOutcomeOf(t *testing.T) {
+	failure := errors.New("handler failed")
+
+	cases := []struct {
+		name     string
+		err      error
+		expected Outcome
+	}{
+		{"success", nil, Ack},
+		{"plain error", failure, Reject},
+		{"requested outcome", WithOutcome(failure, NackNoRequeue), NackNoRequeue},
+		{"wrapped outcome", fmt.Errorf("context: %w", WithOutcome(nil, NackRequeue)), NackRequeue},
+		{"out of range outcome", WithOutcome(failure, Outcome(42)), Reject},
+		{"negative outcome", WithOutcome(failure, Outcome(-1)), Reject},
+	}
+
+	for _, c := range cases {
+		if outcome := outcomeOf(c.err, Reject); outcome != c.expected {
+			t.Errorf("%s: expected %s, got %s", c.name, c.expected.ToString(), outcome.ToString())
+		}
+	}
+}
+
+func TestOutcomeErrorMessageWithUnknownOutcome(t *testing.T) {
+	if message := WithOutcome(nil, Outcome(42)).Error(); message != "messaging: handler requested unknown(42)" {
+		t.Fatalf("unexpected message %q", message)
+	}
+}
+
+func TestFailureOutcomeIgnoresUnknownOutcome(t *testing.T) {
+	config := newConsumerConfiguration().FailureOutcome(Outcome(42))
+
+	if config.failureOutcome != defaultFailureOutcome {
+		t.Fatalf("expected the default failure outcome, got %s", config.failureOutcome.ToString())
+	}
+}
+
+func TestSettleFallsBackToFailureOutcome(t *testing.T) {
+	sub := &subscription{
+		consumer: &Consumer{logger: newTestLogger()},
+		config:   newConsumerConfiguration().AutoAck(false).FailureOutcome(NackNoRequeue),
+	}
+
+	acknowledger := &recordingAcknowledger{}
+	sub.settle(amqp.Delivery{Acknowledger: acknowledger}, Outcome(42))
+
+	if acknowledger.settled != NackNoRequeue.ToString() {
+		t.Fatalf("expected the failure outcome, got %q", acknowledger.settled)
+	}
+}
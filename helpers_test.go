@@ -0,0 +1,23 @@
+package messaging
+
+import (
+	logging "github.com/mitz-it/golang-logging"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/rs/zerolog"
+)
+
+func newTestLogger() *logging.Logger {
+	logger := zerolog.Nop()
+	return &logging.Logger{Standard: &logger}
+}
+
+func newTestProducer(configure ...ConfigureClient) *Producer {
+	return &Producer{
+		connection:      &connection{config: configureClient(configure)},
+		logger:          newTestLogger(),
+		pendingReturns:  make(map[string]*pendingReturn),
+		pendingConfirms: make(map[confirmKey]*Confirmation),
+		earlyConfirms:   make(map[confirmKey]amqp.Confirmation),
+		declared:        newTopologyCache(),
+	}
+}
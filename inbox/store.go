@@ -0,0 +1,158 @@
+package inbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	messaging "github.com/mitz-it/golang-messaging"
+	"github.com/mitz-it/golang-messaging/sqldialect"
+)
+
+const defaultTable string = "inbox_messages"
+
+type txKey struct{}
+
+// schemas holds the inbox table definition for each dialect.
+var schemas = map[sqldialect.Dialect]string{
+	sqldialect.SQLite: `CREATE TABLE IF NOT EXISTS %s (
+	message_id TEXT PRIMARY KEY,
+	processed_at TIMESTAMP NOT NULL
+)`,
+	sqldialect.Postgres: `CREATE TABLE IF NOT EXISTS %s (
+	message_id TEXT PRIMARY KEY,
+	processed_at TIMESTAMPTZ NOT NULL
+)`,
+	sqldialect.MySQL: `CREATE TABLE IF NOT EXISTS %s (
+	message_id VARCHAR(64) PRIMARY KEY,
+	processed_at DATETIME(6) NOT NULL
+)`,
+}
+
+// Store is a messaging.DeduplicationStore backed by database/sql. The inbox
+// row is inserted in a transaction the handler can join through TxFromContext,
+// so the message is recorded as processed exactly when the handler's own
+// writes commit.
+type Store struct {
+	db      *sql.DB
+	table   string
+	dialect sqldialect.Dialect
+}
+
+type entry struct {
+	ctx context.Context
+	tx  *sql.Tx
+}
+
+func NewStore(db *sql.DB, dialect sqldialect.Dialect) *Store {
+	return &Store{
+		db:      db,
+		table:   defaultTable,
+		dialect: dialect,
+	}
+}
+
+func (store *Store) Table(table string) *Store {
+	store.table = table
+	return store
+}
+
+func (store *Store) Schema() string {
+	return fmt.Sprintf(schemas[store.dialect], store.table)
+}
+
+func (store *Store) CreateTable(ctx context.Context) error {
+	_, err := store.db.ExecContext(ctx, store.Schema())
+	return err
+}
+
+func (store *Store) Begin(ctx context.Context, messageId string) (messaging.DeduplicationEntry, error) {
+	tx, err := store.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf("SELECT 1 FROM %s WHERE message_id = %s", store.table, store.dialect.Placeholder(1))
+
+	var found int
+
+	err = tx.QueryRowContext(ctx, query, messageId).Scan(&found)
+
+	if err == nil {
+		tx.Rollback()
+		return nil, messaging.ErrDuplicate
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return nil, err
+	}
+
+	query = fmt.Sprintf(
+		"INSERT INTO %s (message_id, processed_at) VALUES (%s, %s)",
+		store.table,
+		store.dialect.Placeholder(1),
+		store.dialect.Placeholder(2),
+	)
+
+	if _, err := tx.ExecContext(ctx, query, messageId, time.Now().UTC()); err != nil {
+		tx.Rollback()
+		return nil, store.claimFailed(ctx, messageId, err)
+	}
+
+	return &entry{ctx: context.WithValue(ctx, txKey{}, tx), tx: tx}, nil
+}
+
+// claimFailed classifies a failed insert. A unique violation means a
+// concurrent redelivery claimed the message between the lookup and the
+// insert: if its row is committed the message was processed, otherwise it is
+// still being processed.
+func (store *Store) claimFailed(ctx context.Context, messageId string, err error) error {
+	if !store.dialect.IsUniqueViolation(err) {
+		return err
+	}
+
+	query := fmt.Sprintf("SELECT 1 FROM %s WHERE message_id = %s", store.table, store.dialect.Placeholder(1))
+
+	var found int
+
+	if store.db.QueryRowContext(ctx, query, messageId).Scan(&found) == nil {
+		return messaging.ErrDuplicate
+	}
+
+	return messaging.ErrInFlight
+}
+
+// Cleanup deletes inbox rows older than the given age. Redeliveries of those
+// messages are no longer recognised as duplicates.
+func (store *Store) Cleanup(ctx context.Context, olderThan time.Duration) (int64, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE processed_at < %s", store.table, store.dialect.Placeholder(1))
+
+	result, err := store.db.ExecContext(ctx, query, time.Now().UTC().Add(-olderThan))
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func TxFromContext(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
+	return tx, ok
+}
+
+func (entry *entry) Context() context.Context {
+	return entry.ctx
+}
+
+func (entry *entry) Commit() error {
+	return entry.tx.Commit()
+}
+
+func (entry *entry) Rollback() error {
+	return entry.tx.Rollback()
+}
@@ -0,0 +1,145 @@
+package inbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	messaging "github.com/mitz-it/golang-messaging"
+	"github.com/mitz-it/golang-messaging/sqldialect"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every connection to :memory: gets its own database.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	store := NewStore(db, sqldialect.SQLite)
+
+	if err := store.CreateTable(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+// uniqueViolation returns the error the database reports when a message id is
+// inserted twice.
+func uniqueViolation(t *testing.T, store *Store) error {
+	t.Helper()
+
+	insert := "INSERT INTO " + store.table + " (message_id, processed_at) VALUES (?, ?)"
+
+	if _, err := store.db.Exec(insert, "existing", time.Now().UTC()); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := store.db.Exec(insert, "existing", time.Now().UTC())
+
+	if !store.dialect.IsUniqueViolation(err) {
+		t.Fatalf("expected a unique violation, got %v", err)
+	}
+
+	return err
+}
+
+func TestBeginAfterCommitIsDuplicate(t *testing.T) {
+	store := openTestStore(t)
+	ctx := context.Background()
+
+	entry, err := store.Begin(ctx, "message")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := TxFromContext(entry.Context()); !ok {
+		t.Fatal("expected the handler context to carry the inbox transaction")
+	}
+
+	if err := entry.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Begin(ctx, "message"); !errors.Is(err, messaging.ErrDuplicate) {
+		t.Fatalf("expected ErrDuplicate, got %v", err)
+	}
+}
+
+func TestBeginAfterRollbackClaimsAgain(t *testing.T) {
+	store := openTestStore(t)
+	ctx := context.Background()
+
+	entry, err := store.Begin(ctx, "message")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := entry.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err = store.Begin(ctx, "message")
+
+	if err != nil {
+		t.Fatalf("expected a rolled back claim to be released, got %v", err)
+	}
+
+	entry.Rollback()
+}
+
+func TestClaimFailedMapsUniqueViolations(t *testing.T) {
+	store := openTestStore(t)
+	ctx := context.Background()
+	violation := uniqueViolation(t, store)
+
+	if err := store.claimFailed(ctx, "existing", violation); !errors.Is(err, messaging.ErrDuplicate) {
+		t.Fatalf("expected a committed concurrent claim to be ErrDuplicate, got %v", err)
+	}
+
+	if err := store.claimFailed(ctx, "uncommitted", violation); !errors.Is(err, messaging.ErrInFlight) {
+		t.Fatalf("expected an uncommitted concurrent claim to be ErrInFlight, got %v", err)
+	}
+
+	other := errors.New("connection reset")
+
+	if err := store.claimFailed(ctx, "existing", other); err != other {
+		t.Fatalf("expected other errors to pass through, got %v", err)
+	}
+}
+
+func TestCleanupDeletesOldRows(t *testing.T) {
+	store := openTestStore(t)
+	insert := "INSERT INTO " + store.table + " (message_id, processed_at) VALUES (?, ?)"
+	now := time.Now().UTC()
+
+	if _, err := store.db.Exec(insert, "old", now.Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.db.Exec(insert, "recent", now.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := store.Cleanup(context.Background(), time.Hour)
+
+	if err != nil || deleted != 1 {
+		t.Fatalf("expected 1 row deleted, got %d %v", deleted, err)
+	}
+
+	if _, err := store.Begin(context.Background(), "recent"); !errors.Is(err, messaging.ErrDuplicate) {
+		t.Fatalf("expected the recent row to be kept, got %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package messaging
+
+import (
+	"context"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// OutgoingMessage is what an Interceptor sees right before it is published.
+// Headers and properties can be changed in place.
+type OutgoingMessage struct {
+	Exchange   string
+	RoutingKey string
+	Mandatory  bool
+	Immediate  bool
+	amqp.Publishing
+}
+
+type Publish func(ctx context.Context, message *OutgoingMessage) error
+
+// Interceptor wraps a publish. Returning an error without calling next vetoes
+// it, which the producer reports as ErrVetoed.
+type Interceptor func(next Publish) Publish
+
+func chainInterceptors(publish Publish, interceptors ...Interceptor) Publish {
+	for index := len(interceptors) - 1; index >= 0; index-- {
+		publish = interceptors[index](publish)
+	}
+
+	return publish
+}
+
+// HeaderInterceptor sets a header from the publishing context, skipping it when
+// value returns nil.
+func HeaderInterceptor(header string, value func(ctx context.Context) interface{}) Interceptor {
+	return func(next Publish) Publish {
+		return func(ctx context.Context, message *OutgoingMessage) error {
+			if headerValue := value(ctx); headerValue != nil {
+				if message.Headers == nil {
+					message.Headers = amqp.Table{}
+				}
+				message.Headers[header] = headerValue
+			}
+
+			return next(ctx, message)
+		}
+	}
+}
+
+// CorrelationIdInterceptor fills in the correlation id of messages that do not
+// have one yet.
+func CorrelationIdInterceptor(correlationId func(ctx context.Context) string) Interceptor {
+	return func(next Publish) Publish {
+		return func(ctx context.Context, message *OutgoingMessage) error {
+			if message.CorrelationId == "" {
+				message.CorrelationId = correlationId(ctx)
+			}
+
+			return next(ctx, message)
+		}
+	}
+}
+
+func ValidationInterceptor(validate func(ctx context.Context, message *OutgoingMessage) error) Interceptor {
+	return func(next Publish) Publish {
+		return func(ctx context.Context, message *OutgoingMessage) error {
+			if err := validate(ctx, message); err != nil {
+				return err
+			}
+
+			return next(ctx, message)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func interceptedProducer(interceptors ...Interceptor) *Producer {
+	return newTestProducer(func(config *ClientConfiguration) {
+		config.Intercept(interceptors...)
+	})
+}
+
+func TestInterceptorVetoIsErrVetoed(t *testing.T) {
+	rejected := errors.New("missing tenant")
+	producer := interceptedProducer(ValidationInterceptor(func(ctx context.Context, message *OutgoingMessage) error {
+		return rejected
+	}))
+
+	err := producer.intercept(context.Background(), &OutgoingMessage{}, func(ctx context.Context, message *OutgoingMessage) error {
+		t.Fatal("expected a vetoed publish not to be sent")
+		return nil
+	})
+
+	if !errors.Is(err, ErrVetoed) || !errors.Is(err, rejected) {
+		t.Fatalf("expected ErrVetoed wrapping the interceptor error, got %v", err)
+	}
+}
+
+func TestInterceptorErrorAfterSendIsNotVetoed(t *testing.T) {
+	failure := errors.New("audit failed")
+	producer := interceptedProducer(func(next Publish) Publish {
+		return func(ctx context.Context, message *OutgoingMessage) error {
+			if err := next(ctx, message); err != nil {
+				return err
+			}
+			return failure
+		}
+	})
+
+	err := producer.intercept(context.Background(), &OutgoingMessage{}, func(ctx context.Context, message *OutgoingMessage) error {
+		return nil
+	})
+
+	if err != failure || errors.Is(err, ErrVetoed) {
+		t.Fatalf("expected the interceptor error as is, got %v", err)
+	}
+}
+
+func TestSendErrorIsNotVetoed(t *testing.T) {
+	producer := interceptedProducer()
+
+	err := producer.intercept(context.Background(), &OutgoingMessage{}, func(ctx context.Context, message *OutgoingMessage) error {
+		return newError(ErrPublish, errors.New("channel closed"), "Failed to publish message")
+	})
+
+	if !errors.Is(err, ErrPublish) || errors.Is(err, ErrVetoed) {
+		t.Fatalf("expected ErrPublish, got %v", err)
+	}
+}
+
+func TestInterceptorChangesReachSend(t *testing.T) {
+	calls := []string{}
+	record := func(name string) Interceptor {
+		return func(next Publish) Publish {
+			return func(ctx context.Context, message *OutgoingMessage) error {
+				calls = append(calls, name)
+				return next(ctx, message)
+			}
+		}
+	}
+
+	producer := interceptedProducer(
+		record("first"),
+		HeaderInterceptor("tenant", func(ctx context.Context) interface{} { return "acme" }),
+		HeaderInterceptor("skipped", func(ctx context.Context) interface{} { return nil }),
+		CorrelationIdInterceptor(func(ctx context.Context) string { return "generated" }),
+		func(next Publish) Publish {
+			return func(ctx context.Context, message *OutgoingMessage) error {
+				message.RoutingKey = "orders.rerouted"
+				message.Priority = 5
+				return next(ctx, message)
+			}
+		},
+		record("last"),
+	)
+
+	var sent OutgoingMessage
+
+	err := producer.intercept(context.Background(), &OutgoingMessage{RoutingKey: "orders.created"}, func(ctx context.Context, message *OutgoingMessage) error {
+		sent = *message
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sent.Headers["tenant"] != "acme" || len(sent.Headers) != 1 {
+		t.Errorf("expected only the tenant header, got %v", sent.Headers)
+	}
+
+	if sent.CorrelationId != "generated" || sent.RoutingKey != "orders.rerouted" || sent.Priority != 5 {
+		t.Errorf("expected property changes to reach send, got %+v", sent)
+	}
+
+	if !reflect.DeepEqual(calls, []string{"first", "last"}) {
+		t.Errorf("expected interceptors to run in registration order, got %v", calls)
+	}
+}
+
+func TestCorrelationIdInterceptorKeepsExistingId(t *testing.T) {
+	producer := interceptedProducer(CorrelationIdInterceptor(func(ctx context.Context) string { return "generated" }))
+	message := &OutgoingMessage{}
+	message.CorrelationId = "existing"
+
+	producer.intercept(context.Background(), message, func(ctx context.Context, message *OutgoingMessage) error {
+		return nil
+	})
+
+	if message.CorrelationId != "existing" {
+		t.Fatalf("expected the existing correlation id, got %q", message.CorrelationId)
+	}
+}
@@ -0,0 +1,124 @@
+package messaging
+
+import (
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type Metadata struct {
+	Headers       map[string]interface{}
+	Exchange      string
+	RoutingKey    string
+	Redelivered   bool
+	MessageId     string
+	CorrelationId string
+	ReplyTo       string
+	Timestamp     time.Time
+	ContentType   string
+	Type          string
+	AppId         string
+	Deaths        []Death
+}
+
+// Death mirrors one entry of the x-death header the broker adds every time a
+// message is dead-lettered.
+type Death struct {
+	Queue       string
+	Reason      string
+	Exchange    string
+	RoutingKeys []string
+	Count       int64
+	Time        time.Time
+}
+
+type Message struct {
+	Metadata
+	Body   []byte
+	codecs *CodecRegistry
+}
+
+func newMessage(delivery amqp.Delivery, codecs *CodecRegistry) *Message {
+	return &Message{
+		Metadata: Metadata{
+			Headers:       delivery.Headers,
+			Exchange:      delivery.Exchange,
+			RoutingKey:    delivery.RoutingKey,
+			Redelivered:   delivery.Redelivered,
+			MessageId:     delivery.MessageId,
+			CorrelationId: delivery.CorrelationId,
+			ReplyTo:       delivery.ReplyTo,
+			Timestamp:     delivery.Timestamp,
+			ContentType:   delivery.ContentType,
+			Type:          delivery.Type,
+			AppId:         delivery.AppId,
+			Deaths:        parseDeaths(delivery.Headers),
+		},
+		Body:   delivery.Body,
+		codecs: codecs,
+	}
+}
+
+// Decode unmarshals the body with the codec registered for its content type.
+func (message *Message) Decode(target any) error {
+	codecs := message.codecs
+
+	if codecs == nil {
+		codecs = defaultCodecs
+	}
+
+	codec, ok := codecs.Lookup(message.ContentType)
+
+	if !ok {
+		return newError(ErrSerialization, fmt.Errorf("no codec registered for %q", message.ContentType), "Failed to decode message")
+	}
+
+	if err := codec.Unmarshal(message.Body, target); err != nil {
+		return newError(ErrSerialization, err, "Failed to decode message")
+	}
+
+	return nil
+}
+
+func (metadata Metadata) Header(key string) (interface{}, bool) {
+	value, ok := metadata.Headers[key]
+	return value, ok
+}
+
+func parseDeaths(headers amqp.Table) []Death {
+	entries, ok := headers["x-death"].([]interface{})
+
+	if !ok {
+		return nil
+	}
+
+	deaths := make([]Death, 0, len(entries))
+
+	for _, entry := range entries {
+		table, ok := entry.(amqp.Table)
+
+		if !ok {
+			continue
+		}
+
+		death := Death{}
+		death.Queue, _ = table["queue"].(string)
+		death.Reason, _ = table["reason"].(string)
+		death.Exchange, _ = table["exchange"].(string)
+		death.Count, _ = table["count"].(int64)
+		death.Time, _ = table["time"].(time.Time)
+
+		if keys, ok := table["routing-keys"].([]interface{}); ok {
+			for _, key := range keys {
+				if routingKey, ok := key.(string); ok {
+					death.RoutingKeys = append(death.RoutingKeys, routingKey)
+				}
+			}
+		}
+
+		deaths = append(deaths, death)
+	}
+
+	return deaths
+}
@@ -0,0 +1,131 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	logging "github.com/mitz-it/golang-logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const processOperation string = "process"
+
+type Middleware func(next Handler) Handler
+
+// chainMiddlewares wraps handler so the first middleware is the outermost.
+func chainMiddlewares(handler Handler, middlewares ...Middleware) Handler {
+	for index := len(middlewares) - 1; index >= 0; index-- {
+		handler = middlewares[index](handler)
+	}
+
+	return handler
+}
+
+func RecoveryMiddleware(outcome Outcome) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, message *Message) (err error) {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					err = WithOutcome(fmt.Errorf("messaging: handler panicked: %v", recovered), outcome)
+				}
+			}()
+
+			return next(ctx, message)
+		}
+	}
+}
+
+func LoggingMiddleware(logger *logging.Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, message *Message) error {
+			started := time.Now()
+
+			err := next(ctx, message)
+
+			event := logger.Standard.Info()
+			if err != nil {
+				event = logger.Standard.Error().Err(err)
+			}
+
+			event.
+				Str("message-id", message.MessageId).
+				Str("exchange", message.Exchange).
+				Str("routing-key", message.RoutingKey).
+				Bool("redelivered", message.Redelivered).
+				Dur("duration", time.Since(started)).
+				Msg("Consumer: Message handled")
+
+			return err
+		}
+	}
+}
+
+// TimeoutMiddleware cancels the handler context after timeout and fails the
+// delivery at that point. A handler that ignores its context keeps running in
+// the background after the delivery has been settled. A panic in the handler
+// is raised again in the caller, so the consumer's PanicOutcome still applies.
+func TimeoutMiddleware(timeout time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, message *Message) error {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			done := make(chan handlerResult, 1)
+
+			go func() {
+				panicked := true
+
+				defer func() {
+					if panicked {
+						done <- handlerResult{panicked: true, recovered: recover()}
+					}
+				}()
+
+				err := next(ctx, message)
+				panicked = false
+				done <- handlerResult{err: err}
+			}()
+
+			select {
+			case result := <-done:
+				if result.panicked {
+					panic(result.recovered)
+				}
+				return result.err
+			case <-ctx.Done():
+				return fmt.Errorf("messaging: handler timed out after %s: %w", timeout, ctx.Err())
+			}
+		}
+	}
+}
+
+type handlerResult struct {
+	err       error
+	panicked  bool
+	recovered interface{}
+}
+
+// TracingMiddleware records the handler execution as a child span of the
+// receive span the consumer already starts for every delivery.
+func TracingMiddleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, message *Message) error {
+			tracer := otel.Tracer(otel_tracer_name)
+			spanName := fmt.Sprintf("%s %s", valueOrDefault(message.RoutingKey, temporaryDestination), processOperation)
+			ctx, span := tracer.Start(ctx, spanName, trace.WithSpanKind(trace.SpanKindConsumer))
+			defer span.End()
+
+			err := next(ctx, message)
+
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+
+			return err
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func recordingMiddleware(name string, calls *[]string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, message *Message) error {
+			*calls = append(*calls, name+" before")
+			err := next(ctx, message)
+			*calls = append(*calls, name+" after")
+			return err
+		}
+	}
+}
+
+func TestChainMiddlewaresFirstIsOutermost(t *testing.T) {
+	calls := []string{}
+	handler := chainMiddlewares(func(ctx context.Context, message *Message) error {
+		calls = append(calls, "handler")
+		return nil
+	}, recordingMiddleware("first", &calls), recordingMiddleware("second", &calls))
+
+	handler(context.Background(), &Message{})
+
+	expected := []string{"first before", "second before", "handler", "second after", "first after"}
+
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("expected %v, got %v", expected, calls)
+	}
+}
+
+func TestClientMiddlewaresWrapConsumerMiddlewares(t *testing.T) {
+	calls := []string{}
+	consumer := &Consumer{
+		connection: &connection{config: configureClient([]ConfigureClient{func(config *ClientConfiguration) {
+			config.Use(recordingMiddleware("client", &calls))
+		}})},
+		logger:        newTestLogger(),
+		subscriptions: make(map[*subscription]context.CancelFunc),
+	}
+
+	config := newConsumerConfiguration().Use(recordingMiddleware("consumer", &calls))
+	sub, _, err := consumer.subscribe(context.Background(), config, func(ctx context.Context, message *Message) error {
+		calls = append(calls, "handler")
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub.handler(context.Background(), &Message{})
+
+	expected := []string{"client before", "consumer before", "handler", "consumer after", "client after"}
+
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("expected %v, got %v", expected, calls)
+	}
+}
+
+func TestTimeoutMiddlewareReturnsHandlerResult(t *testing.T) {
+	failure := errors.New("handler failed")
+	handler := TimeoutMiddleware(time.Second)(func(ctx context.Context, message *Message) error {
+		return failure
+	})
+
+	if err := handler(context.Background(), &Message{}); err != failure {
+		t.Fatalf("expected the handler error, got %v", err)
+	}
+}
+
+func TestTimeoutMiddlewareTimesOut(t *testing.T) {
+	handler := TimeoutMiddleware(10 * time.Millisecond)(func(ctx context.Context, message *Message) error {
+		<-ctx.Done()
+		return nil
+	})
+
+	if err := handler(context.Background(), &Message{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline error, got %v", err)
+	}
+}
+
+func TestTimeoutMiddlewareKeepsPanicOutcome(t *testing.T) {
+	sub := &subscription{
+		consumer: &Consumer{logger: newTestLogger()},
+		config:   newConsumerConfiguration().FailureOutcome(NackRequeue).PanicOutcome(NackNoRequeue),
+		handler: chainMiddlewares(func(ctx context.Context, message *Message) error {
+			panic("handler exploded")
+		}, TimeoutMiddleware(time.Second)),
+	}
+
+	err := sub.invoke(context.Background(), amqp.Delivery{})
+
+	if outcome := outcomeOf(err, sub.config.failureOutcome); outcome != NackNoRequeue {
+		t.Fatalf("expected the panic outcome, got %s from %v", outcome.ToString(), err)
+	}
+}
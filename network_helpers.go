@@ -48,15 +48,28 @@ func getPortFromConnectionString(connectionString string) int {
 }
 
 func getPeerNames(ip string) []string {
+	if ip == "" {
+		return nil
+	}
 	hosts, _ := net.LookupAddr(ip)
 	return hosts
 }
 
 func getPeerAddresses(host string) []string {
+	if host == "" {
+		return nil
+	}
 	ips, _ := net.LookupHost(host)
 	return ips
 }
 
+func firstOrEmpty(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
 func joinNetworkTagValus(values []string) string {
 	return strings.Join(values, ",")
 }
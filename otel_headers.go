@@ -32,12 +32,12 @@ func (carrier AmqpHeadersCarrier) Keys() []string {
 	return keys
 }
 
-func (producer Producer) InjectAMQPHeaders(ctx context.Context) map[string]interface{} {
+func (producer *Producer) InjectAMQPHeaders(ctx context.Context) map[string]interface{} {
 	carrier := make(AmqpHeadersCarrier)
 	otel.GetTextMapPropagator().Inject(ctx, carrier)
 	return carrier
 }
 
-func (consumer Consumer) ExtractAMQPHeader(ctx context.Context, headers map[string]interface{}) context.Context {
+func (consumer *Consumer) ExtractAMQPHeader(ctx context.Context, headers map[string]interface{}) context.Context {
 	return otel.GetTextMapPropagator().Extract(ctx, AmqpHeadersCarrier(headers))
 }
@@ -83,7 +83,7 @@ func setNetTags(span trace.Span, connectionString string) {
 
 	if match, ip := isIPAddress(hostOrIp); match {
 		peerNames := getPeerNames(ip)
-		peerAddresses := getPeerAddresses(peerNames[0])
+		peerAddresses := getPeerAddresses(firstOrEmpty(peerNames))
 		peer_name_value := joinNetworkTagValus(peerNames)
 		peer_addr_value := joinNetworkTagValus(peerAddresses)
 
@@ -92,7 +92,7 @@ func setNetTags(span trace.Span, connectionString string) {
 	} else {
 		host := hostOrIp
 		peerAddresses := getPeerAddresses(host)
-		peerNames := getPeerNames(peerAddresses[0])
+		peerNames := getPeerNames(firstOrEmpty(peerAddresses))
 		peer_name_value := joinNetworkTagValus(peerNames)
 		peer_addr_value := joinNetworkTagValus(peerAddresses)
 
@@ -0,0 +1,125 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	messaging "github.com/mitz-it/golang-messaging"
+	"github.com/mitz-it/golang-messaging/sqldialect"
+)
+
+const defaultTable string = "outbox_messages"
+
+type Message struct {
+	Exchange   string
+	RoutingKey string
+	Headers    map[string]interface{}
+	Data       any
+	Codec      messaging.Codec
+}
+
+// schemas holds the outbox table definition for each dialect.
+var schemas = map[sqldialect.Dialect]string{
+	sqldialect.SQLite: `CREATE TABLE IF NOT EXISTS %s (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	message_id TEXT NOT NULL UNIQUE,
+	exchange TEXT NOT NULL,
+	routing_key TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	headers TEXT,
+	body BLOB NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	dispatched_at TIMESTAMP NULL
+)`,
+	sqldialect.Postgres: `CREATE TABLE IF NOT EXISTS %s (
+	id BIGSERIAL PRIMARY KEY,
+	message_id TEXT NOT NULL UNIQUE,
+	exchange TEXT NOT NULL,
+	routing_key TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	headers TEXT,
+	body BYTEA NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	dispatched_at TIMESTAMPTZ NULL
+)`,
+	sqldialect.MySQL: `CREATE TABLE IF NOT EXISTS %s (
+	id BIGINT AUTO_INCREMENT PRIMARY KEY,
+	message_id VARCHAR(64) NOT NULL UNIQUE,
+	exchange VARCHAR(255) NOT NULL,
+	routing_key VARCHAR(255) NOT NULL,
+	content_type VARCHAR(255) NOT NULL,
+	headers TEXT,
+	body LONGBLOB NOT NULL,
+	created_at DATETIME(6) NOT NULL,
+	dispatched_at DATETIME(6) NULL
+)`,
+}
+
+type Outbox struct {
+	table   string
+	dialect sqldialect.Dialect
+}
+
+func New(dialect sqldialect.Dialect) *Outbox {
+	return &Outbox{
+		table:   defaultTable,
+		dialect: dialect,
+	}
+}
+
+func (outbox *Outbox) Table(table string) *Outbox {
+	outbox.table = table
+	return outbox
+}
+
+func (outbox *Outbox) Schema() string {
+	return fmt.Sprintf(schemas[outbox.dialect], outbox.table)
+}
+
+func (outbox *Outbox) CreateTable(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, outbox.Schema())
+	return err
+}
+
+// Enqueue stores the message in the caller's transaction, so it is published
+// by the Relay if and only if the transaction commits. It returns the message
+// id the message will be published with.
+func (outbox *Outbox) Enqueue(ctx context.Context, tx *sql.Tx, message Message) (string, error) {
+	codec := message.Codec
+
+	if codec == nil {
+		codec = messaging.JsonCodec{}
+	}
+
+	body, err := codec.Marshal(message.Data)
+
+	if err != nil {
+		return "", &messaging.Error{Kind: messaging.ErrSerialization, Message: "Failed to serialize outbox message", Err: err}
+	}
+
+	headers, err := json.Marshal(message.Headers)
+
+	if err != nil {
+		return "", &messaging.Error{Kind: messaging.ErrSerialization, Message: "Failed to serialize outbox headers", Err: err}
+	}
+
+	messageId := uuid.New().String()
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (message_id, exchange, routing_key, content_type, headers, body, created_at) VALUES (%s)",
+		outbox.table,
+		outbox.dialect.Placeholders(1, 7),
+	)
+
+	_, err = tx.ExecContext(ctx, query, messageId, message.Exchange, message.RoutingKey, string(codec.ContentType()), string(headers), body, time.Now().UTC())
+
+	if err != nil {
+		return "", err
+	}
+
+	return messageId, nil
+}
@@ -0,0 +1,252 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	logging "github.com/mitz-it/golang-logging"
+	messaging "github.com/mitz-it/golang-messaging"
+)
+
+const defaultBatchSize int = 100
+const defaultPollInterval time.Duration = time.Second
+const defaultRetention time.Duration = 7 * 24 * time.Hour
+const defaultCleanupInterval time.Duration = time.Hour
+
+type RelayConfiguration struct {
+	batchSize       int
+	pollInterval    time.Duration
+	retention       time.Duration
+	cleanupInterval time.Duration
+	configure       messaging.ConfigureProducer
+}
+
+type ConfigureRelay func(config *RelayConfiguration)
+
+// Relay publishes pending outbox rows in insertion order with publisher
+// confirms, marking each row dispatched once the broker confirmed it. A row
+// that fails stops the batch so later rows are not published ahead of it.
+// Run a single relay per outbox table.
+type Relay struct {
+	db       *sql.DB
+	outbox   *Outbox
+	producer messaging.IProducer
+	logger   *logging.Logger
+	config   *RelayConfiguration
+}
+
+type row struct {
+	id          int64
+	messageId   string
+	exchange    string
+	routingKey  string
+	contentType string
+	headers     sql.NullString
+	body        []byte
+}
+
+type rawCodec struct {
+	contentType messaging.ContentType
+}
+
+func (codec rawCodec) ContentType() messaging.ContentType {
+	return codec.contentType
+}
+
+func (codec rawCodec) Marshal(message any) ([]byte, error) {
+	return messaging.BytesCodec{}.Marshal(message)
+}
+
+func (codec rawCodec) Unmarshal(data []byte, message any) error {
+	return messaging.BytesCodec{}.Unmarshal(data, message)
+}
+
+func newRelayConfiguration() *RelayConfiguration {
+	return &RelayConfiguration{
+		batchSize:       defaultBatchSize,
+		pollInterval:    defaultPollInterval,
+		retention:       defaultRetention,
+		cleanupInterval: defaultCleanupInterval,
+		configure:       nil,
+	}
+}
+
+func NewRelay(logger *logging.Logger, db *sql.DB, outbox *Outbox, producer messaging.IProducer, configure ConfigureRelay) *Relay {
+	config := newRelayConfiguration()
+
+	if configure != nil {
+		configure(config)
+	}
+
+	return &Relay{
+		db:       db,
+		outbox:   outbox,
+		producer: producer,
+		logger:   logger,
+		config:   config,
+	}
+}
+
+func (relay *Relay) Run(ctx context.Context) error {
+	lastCleanup := time.Now()
+
+	for {
+		dispatched, err := relay.DispatchPending(ctx)
+
+		if err != nil {
+			relay.logger.Standard.Error().Err(err).Msg("Outbox: Failed to dispatch pending messages")
+		}
+
+		if relay.config.retention > 0 && time.Since(lastCleanup) >= relay.config.cleanupInterval {
+			if _, err := relay.Cleanup(ctx); err != nil {
+				relay.logger.Standard.Error().Err(err).Msg("Outbox: Failed to clean up dispatched messages")
+			}
+			lastCleanup = time.Now()
+		}
+
+		if dispatched == relay.config.batchSize && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(relay.config.pollInterval):
+		}
+	}
+}
+
+func (relay *Relay) DispatchPending(ctx context.Context) (int, error) {
+	rows, err := relay.pending(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	for index, pending := range rows {
+		if err := relay.dispatch(ctx, pending); err != nil {
+			return index, err
+		}
+	}
+
+	return len(rows), nil
+}
+
+func (relay *Relay) pending(ctx context.Context) ([]row, error) {
+	query := fmt.Sprintf(
+		"SELECT id, message_id, exchange, routing_key, content_type, headers, body FROM %s WHERE dispatched_at IS NULL ORDER BY id LIMIT %d",
+		relay.outbox.table,
+		relay.config.batchSize,
+	)
+
+	result, err := relay.db.QueryContext(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer result.Close()
+
+	rows := []row{}
+
+	for result.Next() {
+		pending := row{}
+
+		if err := result.Scan(&pending.id, &pending.messageId, &pending.exchange, &pending.routingKey, &pending.contentType, &pending.headers, &pending.body); err != nil {
+			return nil, err
+		}
+
+		rows = append(rows, pending)
+	}
+
+	return rows, result.Err()
+}
+
+func (relay *Relay) dispatch(ctx context.Context, pending row) error {
+	headers := map[string]interface{}{}
+
+	if pending.headers.Valid && pending.headers.String != "" {
+		if err := json.Unmarshal([]byte(pending.headers.String), &headers); err != nil {
+			return &messaging.Error{Kind: messaging.ErrSerialization, Message: "Failed to deserialize outbox headers", Err: err}
+		}
+	}
+
+	messageEnvelop := messaging.MessageEnvelop{
+		Headers: headers,
+		Data:    pending.body,
+	}
+
+	err := relay.producer.TryProduceWithEnvelop(ctx, messageEnvelop, func(config *messaging.ProducerConfiguration) {
+		if relay.config.configure != nil {
+			relay.config.configure(config)
+		}
+		config.Exchange(pending.exchange).
+			RoutingKey(pending.routingKey).
+			MessageId(pending.messageId).
+			Codec(rawCodec{contentType: messaging.ContentType(pending.contentType)}).
+			Confirm(true)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET dispatched_at = %s WHERE id = %s",
+		relay.outbox.table,
+		relay.outbox.dialect.Placeholder(1),
+		relay.outbox.dialect.Placeholder(2),
+	)
+
+	_, err = relay.db.ExecContext(ctx, query, time.Now().UTC(), pending.id)
+
+	return err
+}
+
+// Cleanup deletes rows dispatched longer ago than the retention period.
+func (relay *Relay) Cleanup(ctx context.Context) (int64, error) {
+	query := fmt.Sprintf(
+		"DELETE FROM %s WHERE dispatched_at IS NOT NULL AND dispatched_at < %s",
+		relay.outbox.table,
+		relay.outbox.dialect.Placeholder(1),
+	)
+
+	result, err := relay.db.ExecContext(ctx, query, time.Now().UTC().Add(-relay.config.retention))
+
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (config *RelayConfiguration) BatchSize(size int) *RelayConfiguration {
+	config.batchSize = size
+	return config
+}
+
+func (config *RelayConfiguration) PollInterval(interval time.Duration) *RelayConfiguration {
+	config.pollInterval = interval
+	return config
+}
+
+// Retention is how long dispatched rows are kept; zero disables cleanup.
+func (config *RelayConfiguration) Retention(retention time.Duration) *RelayConfiguration {
+	config.retention = retention
+	return config
+}
+
+func (config *RelayConfiguration) CleanupInterval(interval time.Duration) *RelayConfiguration {
+	config.cleanupInterval = interval
+	return config
+}
+
+// Producer customises how rows are published, e.g. Mandatory or Timeout. The
+// exchange, routing key, message id, codec and confirms come from the row.
+func (config *RelayConfiguration) Producer(configure messaging.ConfigureProducer) *RelayConfiguration {
+	config.configure = configure
+	return config
+}
@@ -0,0 +1,304 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	logging "github.com/mitz-it/golang-logging"
+	messaging "github.com/mitz-it/golang-messaging"
+	"github.com/mitz-it/golang-messaging/sqldialect"
+	"github.com/rs/zerolog"
+)
+
+var errBroker = errors.New("broker unavailable")
+
+// fakeProducer records the bodies it was asked to publish and fails the
+// publish whose body equals failOn.
+type fakeProducer struct {
+	published []string
+	headers   []map[string]interface{}
+	failOn    string
+}
+
+func (producer *fakeProducer) TryProduceWithEnvelop(ctx context.Context, messageEnvelop messaging.MessageEnvelop, configure messaging.ConfigureProducer) error {
+	body := string(messageEnvelop.Data.([]byte))
+
+	if body == producer.failOn {
+		return errBroker
+	}
+
+	producer.published = append(producer.published, body)
+	producer.headers = append(producer.headers, messageEnvelop.Headers)
+
+	return nil
+}
+
+func (producer *fakeProducer) ProduceWithEnvelop(ctx context.Context, messageEnvelop messaging.MessageEnvelop, configure messaging.ConfigureProducer) {
+}
+
+func (producer *fakeProducer) Produce(ctx context.Context, message any, configure messaging.ConfigureProducer) {
+}
+
+func (producer *fakeProducer) TryProduce(ctx context.Context, message any, configure messaging.ConfigureProducer) error {
+	return nil
+}
+
+func (producer *fakeProducer) ProduceDeferred(ctx context.Context, messageEnvelop messaging.MessageEnvelop, configure messaging.ConfigureProducer) (*messaging.Confirmation, error) {
+	return nil, nil
+}
+
+func (producer *fakeProducer) ProduceBatch(ctx context.Context, messageEnvelops []messaging.MessageEnvelop, configure messaging.ConfigureProducer) ([]messaging.BatchResult, error) {
+	return nil, nil
+}
+
+func (producer *fakeProducer) DeclareTopology(ctx context.Context, configure messaging.ConfigureProducer) error {
+	return nil
+}
+
+func (producer *fakeProducer) ApplyTopology(ctx context.Context, topology *messaging.Topology) error {
+	return nil
+}
+
+func (producer *fakeProducer) Close(ctx context.Context) error {
+	return nil
+}
+
+func newTestLogger() *logging.Logger {
+	logger := zerolog.Nop()
+	return &logging.Logger{Standard: &logger}
+}
+
+func openTestDatabase(t *testing.T, outbox *Outbox) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every connection to :memory: gets its own database.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	if err := outbox.CreateTable(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func enqueue(t *testing.T, db *sql.DB, outbox *Outbox, bodies ...string) []string {
+	t.Helper()
+
+	ctx := context.Background()
+	tx, err := db.BeginTx(ctx, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []string{}
+
+	for _, body := range bodies {
+		id, err := outbox.Enqueue(ctx, tx, Message{
+			Exchange:   "orders",
+			RoutingKey: "created",
+			Headers:    map[string]interface{}{"body": body},
+			Data:       []byte(body),
+			Codec:      messaging.BytesCodec{},
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	return ids
+}
+
+func countPending(t *testing.T, db *sql.DB, outbox *Outbox) int {
+	t.Helper()
+
+	var count int
+
+	if err := db.QueryRow("SELECT COUNT(*) FROM " + outbox.table + " WHERE dispatched_at IS NULL").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+
+	return count
+}
+
+func TestEnqueueRollbackDiscardsMessage(t *testing.T) {
+	outbox := New(sqldialect.SQLite)
+	db := openTestDatabase(t, outbox)
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := outbox.Enqueue(ctx, tx, Message{Exchange: "orders", Data: map[string]int{"id": 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	if count := countPending(t, db, outbox); count != 0 {
+		t.Fatalf("expected no rows after rollback, got %d", count)
+	}
+}
+
+func TestDispatchPendingPublishesInIdOrder(t *testing.T) {
+	outbox := New(sqldialect.SQLite)
+	db := openTestDatabase(t, outbox)
+	producer := &fakeProducer{}
+	relay := NewRelay(newTestLogger(), db, outbox, producer, nil)
+
+	enqueue(t, db, outbox, "1", "2", "3")
+
+	dispatched, err := relay.DispatchPending(context.Background())
+
+	if err != nil || dispatched != 3 {
+		t.Fatalf("expected 3 dispatched, got %d %v", dispatched, err)
+	}
+
+	for index, body := range []string{"1", "2", "3"} {
+		if producer.published[index] != body {
+			t.Fatalf("expected %v in insertion order, got %v", []string{"1", "2", "3"}, producer.published)
+		}
+
+		if producer.headers[index]["body"] != body {
+			t.Fatalf("expected headers to be restored, got %v", producer.headers[index])
+		}
+	}
+
+	if count := countPending(t, db, outbox); count != 0 {
+		t.Fatalf("expected every row dispatched, %d pending", count)
+	}
+}
+
+func TestDispatchPendingStopsAtFailedRow(t *testing.T) {
+	outbox := New(sqldialect.SQLite)
+	db := openTestDatabase(t, outbox)
+	producer := &fakeProducer{failOn: "2"}
+	relay := NewRelay(newTestLogger(), db, outbox, producer, nil)
+
+	enqueue(t, db, outbox, "1", "2", "3")
+
+	dispatched, err := relay.DispatchPending(context.Background())
+
+	if !errors.Is(err, errBroker) || dispatched != 1 {
+		t.Fatalf("expected the batch to stop after 1 row, got %d %v", dispatched, err)
+	}
+
+	if len(producer.published) != 1 || producer.published[0] != "1" {
+		t.Fatalf("expected rows after the failure to be held back, got %v", producer.published)
+	}
+
+	if count := countPending(t, db, outbox); count != 2 {
+		t.Fatalf("expected 2 pending rows, got %d", count)
+	}
+
+	producer.failOn = ""
+
+	if dispatched, err := relay.DispatchPending(context.Background()); err != nil || dispatched != 2 {
+		t.Fatalf("expected the remaining rows on retry, got %d %v", dispatched, err)
+	}
+
+	if producer.published[1] != "2" || producer.published[2] != "3" {
+		t.Fatalf("expected the failed row to be retried first, got %v", producer.published)
+	}
+}
+
+func TestDispatchPendingRespectsBatchSize(t *testing.T) {
+	outbox := New(sqldialect.SQLite)
+	db := openTestDatabase(t, outbox)
+	producer := &fakeProducer{}
+	relay := NewRelay(newTestLogger(), db, outbox, producer, func(config *RelayConfiguration) {
+		config.BatchSize(2)
+	})
+
+	bodies := []string{}
+
+	for index := 1; index <= 5; index++ {
+		bodies = append(bodies, strconv.Itoa(index))
+	}
+
+	enqueue(t, db, outbox, bodies...)
+
+	for _, expected := range []int{2, 2, 1, 0} {
+		dispatched, err := relay.DispatchPending(context.Background())
+
+		if err != nil || dispatched != expected {
+			t.Fatalf("expected %d dispatched, got %d %v", expected, dispatched, err)
+		}
+	}
+
+	if len(producer.published) != 5 {
+		t.Fatalf("expected 5 published, got %v", producer.published)
+	}
+}
+
+func TestCleanupRespectsRetention(t *testing.T) {
+	outbox := New(sqldialect.SQLite)
+	db := openTestDatabase(t, outbox)
+	relay := NewRelay(newTestLogger(), db, outbox, &fakeProducer{}, func(config *RelayConfiguration) {
+		config.Retention(time.Hour)
+	})
+
+	ids := enqueue(t, db, outbox, "old", "recent", "pending")
+
+	now := time.Now().UTC()
+	update := "UPDATE " + outbox.table + " SET dispatched_at = ? WHERE message_id = ?"
+
+	if _, err := db.Exec(update, now.Add(-2*time.Hour), ids[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Exec(update, now.Add(-time.Minute), ids[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := relay.Cleanup(context.Background())
+
+	if err != nil || deleted != 1 {
+		t.Fatalf("expected 1 row deleted, got %d %v", deleted, err)
+	}
+
+	var remaining []string
+	result, err := db.Query("SELECT message_id FROM " + outbox.table + " ORDER BY id")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer result.Close()
+
+	for result.Next() {
+		var id string
+		if err := result.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		remaining = append(remaining, id)
+	}
+
+	if len(remaining) != 2 || remaining[0] != ids[1] || remaining[1] != ids[2] {
+		t.Fatalf("expected the recent and pending rows to remain, got %v", remaining)
+	}
+}
@@ -0,0 +1,45 @@
+package messaging
+
+import "fmt"
+
+// PartitionKey extracts the key deliveries are ordered by. Deliveries for which
+// it returns an empty key are spread across workers without ordering.
+type PartitionKey func(message *Message) string
+
+func HeaderPartitionKey(header string) PartitionKey {
+	return func(message *Message) string {
+		value, ok := message.Header(header)
+
+		if !ok || value == nil {
+			return ""
+		}
+
+		return fmt.Sprint(value)
+	}
+}
+
+func RoutingKeyPartitionKey() PartitionKey {
+	return func(message *Message) string {
+		return message.RoutingKey
+	}
+}
+
+// PayloadPartitionKey decodes the body with the consumer's codecs and reads a
+// top-level field from it.
+func PayloadPartitionKey(field string) PartitionKey {
+	return func(message *Message) string {
+		payload := map[string]interface{}{}
+
+		if err := message.Decode(&payload); err != nil {
+			return ""
+		}
+
+		value, ok := payload[field]
+
+		if !ok || value == nil {
+			return ""
+		}
+
+		return fmt.Sprint(value)
+	}
+}
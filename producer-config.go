@@ -4,6 +4,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
+	logging "github.com/mitz-it/golang-logging"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
@@ -12,9 +14,16 @@ type ProducerConfiguration struct {
 	QueueConfig    *queueConfiguration
 	routingKey     string
 	contentType    ContentType
+	codec          Codec
 	mandatory      bool
 	immediate      bool
 	timeOut        time.Duration
+	confirm        bool
+	exchange       string
+	messageId      string
+	correlationId  string
+	replyTo        string
+	appId          string
 }
 
 type ConfigureProducer func(config *ProducerConfiguration)
@@ -24,10 +33,17 @@ func newProducerConfiguration() *ProducerConfiguration {
 		ExchangeConfig: nil,
 		QueueConfig:    nil,
 		contentType:    ApplicationJson,
+		codec:          JsonCodec{},
 		mandatory:      defaultMandatory,
 		immediate:      defaultImmediate,
 		routingKey:     defaultRoutingKey,
 		timeOut:        defaultContextTimeOut,
+		confirm:        defaultConfirm,
+		exchange:       emptyExchangeName,
+		messageId:      "",
+		correlationId:  "",
+		replyTo:        "",
+		appId:          "",
 	}
 }
 
@@ -57,23 +73,32 @@ func (config *ProducerConfiguration) getKey(queue *amqp.Queue) string {
 
 func (config *ProducerConfiguration) getExchange() string {
 	if config.ExchangeConfig == nil {
-		return emptyExchangeName
+		return config.exchange
 	}
 	return config.ExchangeConfig.name
 }
 
-func (config *ProducerConfiguration) bindQueueToExchange(channel *amqp.Channel, queue *amqp.Queue, args amqp.Table) {
+func (config *ProducerConfiguration) getMessageId() string {
+	if config.messageId == "" {
+		return uuid.New().String()
+	}
+	return config.messageId
+}
+
+func (config *ProducerConfiguration) bindQueueToExchange(logger *logging.Logger, channel *amqp.Channel, queue *amqp.Queue, args amqp.Table) error {
 	if config.ExchangeConfig == nil || config.QueueConfig == nil {
-		return
+		return nil
 	}
 
-	channel.QueueBind(
+	err := channel.QueueBind(
 		queue.Name,
 		config.routingKey,
 		config.ExchangeConfig.name,
 		config.ExchangeConfig.noWait,
 		args,
 	)
+
+	return handleError(logger, ErrDeclaration, err, "Failed to bind queue to exchange")
 }
 
 func (config *ProducerConfiguration) RoutingKey(routingKey string) *ProducerConfiguration {
@@ -81,11 +106,19 @@ func (config *ProducerConfiguration) RoutingKey(routingKey string) *ProducerConf
 	return config
 }
 
+// ContentType only sets the content-type property; the body is still encoded
+// with the configured codec. Use Codec to change the wire format.
 func (config *ProducerConfiguration) ContentType(contentType ContentType) *ProducerConfiguration {
 	config.contentType = contentType
 	return config
 }
 
+func (config *ProducerConfiguration) Codec(codec Codec) *ProducerConfiguration {
+	config.codec = codec
+	config.contentType = codec.ContentType()
+	return config
+}
+
 func (config *ProducerConfiguration) Mandatory(mandatory bool) *ProducerConfiguration {
 	config.mandatory = mandatory
 	return config
@@ -100,3 +133,39 @@ func (config *ProducerConfiguration) Timeout(timeout time.Duration) *ProducerCon
 	config.timeOut = timeout
 	return config
 }
+
+// Confirm puts the channel in publisher confirm mode and makes Produce wait
+// until the broker acks or nacks the message, within Timeout.
+func (config *ProducerConfiguration) Confirm(confirm bool) *ProducerConfiguration {
+	config.confirm = confirm
+	return config
+}
+
+// Exchange publishes to an existing exchange without declaring it. It is
+// ignored when ExchangeConfig is set.
+func (config *ProducerConfiguration) Exchange(name string) *ProducerConfiguration {
+	config.exchange = name
+	return config
+}
+
+func (config *ProducerConfiguration) MessageId(messageId string) *ProducerConfiguration {
+	config.messageId = messageId
+	return config
+}
+
+func (config *ProducerConfiguration) CorrelationId(correlationId string) *ProducerConfiguration {
+	config.correlationId = correlationId
+	return config
+}
+
+func (config *ProducerConfiguration) ReplyTo(replyTo string) *ProducerConfiguration {
+	config.replyTo = replyTo
+	return config
+}
+
+// AppId identifies the publishing application, e.g. a responder answering a
+// Gather request.
+func (config *ProducerConfiguration) AppId(appId string) *ProducerConfiguration {
+	config.appId = appId
+	return config
+}
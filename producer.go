@@ -2,10 +2,10 @@ package messaging
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"sync"
 	"time"
 
-	"github.com/google/uuid"
 	logging "github.com/mitz-it/golang-logging"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
@@ -13,13 +13,29 @@ import (
 type IProducer interface {
 	ProduceWithEnvelop(ctx context.Context, messageEnvelop MessageEnvelop, configure ConfigureProducer)
 	Produce(ctx context.Context, message any, configure ConfigureProducer)
+	TryProduceWithEnvelop(ctx context.Context, messageEnvelop MessageEnvelop, configure ConfigureProducer) error
+	TryProduce(ctx context.Context, message any, configure ConfigureProducer) error
+	ProduceDeferred(ctx context.Context, messageEnvelop MessageEnvelop, configure ConfigureProducer) (*Confirmation, error)
+	ProduceBatch(ctx context.Context, messageEnvelops []MessageEnvelop, configure ConfigureProducer) ([]BatchResult, error)
+	DeclareTopology(ctx context.Context, configure ConfigureProducer) error
+	ApplyTopology(ctx context.Context, topology *Topology) error
+	Close(ctx context.Context) error
 }
 
 type Producer struct {
 	connectionString string
-	connection       *amqp.Connection
+	connection       *connection
 	channel          *amqp.Channel
 	logger           *logging.Logger
+	mutex            sync.Mutex
+	confirming       bool
+	returnsMutex     sync.Mutex
+	pendingReturns   map[string]*pendingReturn
+	confirmsMutex    sync.Mutex
+	pendingConfirms  map[confirmKey]*Confirmation
+	earlyConfirms    map[confirmKey]amqp.Confirmation
+	onChannelOpened  func(channel *amqp.Channel) error
+	declared         *topologyCache
 }
 
 type MessageEnvelop struct {
@@ -27,51 +43,207 @@ type MessageEnvelop struct {
 	Data    any
 }
 
+// Deprecated: use TryProduceWithEnvelop, which returns the error instead of panicking.
 func (producer *Producer) ProduceWithEnvelop(ctx context.Context, messageEnvelop MessageEnvelop, configure ConfigureProducer) {
-	producer.produce(ctx, messageEnvelop, configure)
+	err := producer.TryProduceWithEnvelop(ctx, messageEnvelop, configure)
+	failOnError(producer.logger, err, "Failed to produce message")
 }
 
+// Deprecated: use TryProduce, which returns the error instead of panicking.
 func (producer *Producer) Produce(ctx context.Context, message any, configure ConfigureProducer) {
+	err := producer.TryProduce(ctx, message, configure)
+	failOnError(producer.logger, err, "Failed to produce message")
+}
+
+func (producer *Producer) TryProduceWithEnvelop(ctx context.Context, messageEnvelop MessageEnvelop, configure ConfigureProducer) error {
+	return producer.produce(ctx, messageEnvelop, configure)
+}
+
+func (producer *Producer) TryProduce(ctx context.Context, message any, configure ConfigureProducer) error {
 	messageEnvelop := MessageEnvelop{
 		Data: message,
 	}
 
-	producer.produce(ctx, messageEnvelop, configure)
+	return producer.produce(ctx, messageEnvelop, configure)
 }
 
-func (producer *Producer) produce(ctx context.Context, messageEnvelop MessageEnvelop, configure ConfigureProducer) {
-	message := messageEnvelop.Data
+// ProduceDeferred publishes in confirm mode without waiting for the broker,
+// returning a Confirmation the caller can await later.
+func (producer *Producer) ProduceDeferred(ctx context.Context, messageEnvelop MessageEnvelop, configure ConfigureProducer) (*Confirmation, error) {
+	config := configureProducer(configure, messageEnvelop.Data)
+	config.confirm = true
 
-	config := configureProducer(configure, message)
+	ctx, cancel := context.WithTimeout(ctx, config.timeOut*time.Second)
+	defer cancel()
 
-	declareExchange(producer.logger, producer.channel, config.ExchangeConfig)
+	return producer.publish(ctx, config, messageEnvelop)
+}
 
-	queue := declareQueue(producer.logger, producer.channel, config.QueueConfig)
+// DeclareTopology declares the configured exchange, queue and binding up
+// front. Later publishes on the same channel find them cached and go straight
+// to the broker without redeclaring.
+func (producer *Producer) DeclareTopology(ctx context.Context, configure ConfigureProducer) error {
+	config := configureProducer(configure, nil)
 
-	args := config.toArgumentsTable()
+	ctx, cancel := context.WithTimeout(ctx, config.timeOut*time.Second)
+	defer cancel()
 
-	config.bindQueueToExchange(
-		producer.channel,
-		queue,
-		args,
-	)
+	_, _, err := producer.declare(ctx, config)
+
+	return err
+}
+
+func (producer *Producer) ApplyTopology(ctx context.Context, topology *Topology) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultContextTimeOut*time.Second)
+	defer cancel()
+
+	channel, err := producer.getChannel(ctx, false)
 
-	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	return topology.Apply(producer.logger, channel)
+}
 
-	failOnError(producer.logger, err, "Failed to serialize message")
+func (producer *Producer) produce(ctx context.Context, messageEnvelop MessageEnvelop, configure ConfigureProducer) error {
+	config := configureProducer(configure, messageEnvelop.Data)
 
 	ctx, cancel := context.WithTimeout(ctx, config.timeOut*time.Second)
 	defer cancel()
 
+	confirmation, err := producer.publish(ctx, config, messageEnvelop)
+
+	if err != nil || confirmation == nil {
+		return err
+	}
+
+	return confirmation.Wait(ctx)
+}
+
+func (producer *Producer) publish(ctx context.Context, config *ProducerConfiguration, messageEnvelop MessageEnvelop) (*Confirmation, error) {
+	channel, queue, err := producer.declare(ctx, config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, confirmation, err := producer.publishMessage(ctx, channel, queue, config, messageEnvelop)
+
+	return confirmation, err
+}
+
+func (producer *Producer) declare(ctx context.Context, config *ProducerConfiguration) (*amqp.Channel, *amqp.Queue, error) {
+	channel, err := producer.getChannel(ctx, config.confirm)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := producer.declareExchange(channel, config.ExchangeConfig); err != nil {
+		return nil, nil, err
+	}
+
+	queue, err := producer.declareQueue(channel, config.QueueConfig)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := producer.bindQueueToExchange(channel, config, queue); err != nil {
+		return nil, nil, err
+	}
+
+	return channel, queue, nil
+}
+
+func (producer *Producer) declareExchange(channel *amqp.Channel, config *exchangeConfiguration) error {
+	if config == nil {
+		return nil
+	}
+
+	key := exchangeCacheKey(config)
+
+	if producer.declared.hasExchange(channel, key) {
+		return nil
+	}
+
+	if err := declareExchange(producer.logger, channel, config); err != nil {
+		return err
+	}
+
+	producer.declared.addExchange(channel, key)
+
+	return nil
+}
+
+func (producer *Producer) declareQueue(channel *amqp.Channel, config *queueConfiguration) (*amqp.Queue, error) {
+	if config == nil {
+		return nil, nil
+	}
+
+	key := queueCacheKey(config)
+
+	if queue, ok := producer.declared.getQueue(channel, key); ok {
+		return queue, nil
+	}
+
+	queue, err := declareQueue(producer.logger, channel, config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	producer.declared.addQueue(channel, key, queue)
+
+	return queue, nil
+}
+
+func (producer *Producer) bindQueueToExchange(channel *amqp.Channel, config *ProducerConfiguration, queue *amqp.Queue) error {
+	if config.ExchangeConfig == nil || config.QueueConfig == nil {
+		return nil
+	}
+
+	args := config.toArgumentsTable()
+	key := ""
+
+	// A binding goes away with its queue, so it is cached only with the queue.
+	if queueCacheKey(config.QueueConfig) != "" {
+		key = bindingCacheKey(queue.Name, config.routingKey, config.ExchangeConfig.name, args)
+	}
+
+	if producer.declared.hasBinding(channel, key) {
+		return nil
+	}
+
+	if err := config.bindQueueToExchange(producer.logger, channel, queue, args); err != nil {
+		return err
+	}
+
+	producer.declared.addBinding(channel, key)
+
+	return nil
+}
+
+func (producer *Producer) publishMessage(ctx context.Context, channel *amqp.Channel, queue *amqp.Queue, config *ProducerConfiguration, messageEnvelop MessageEnvelop) (string, *Confirmation, error) {
+	body, err := config.codec.Marshal(messageEnvelop.Data)
+
+	if err != nil {
+		return "", nil, handleError(producer.logger, ErrSerialization, err, "Failed to serialize message")
+	}
+
 	key := config.getKey(queue)
 
 	exchange := config.getExchange()
 
 	msg := amqp.Publishing{
-		DeliveryMode: amqp.Persistent,
-		ContentType:  string(config.contentType),
-		Body:         body,
-		MessageId:    uuid.New().String(),
+		DeliveryMode:  amqp.Persistent,
+		ContentType:   string(config.contentType),
+		Body:          body,
+		MessageId:     config.getMessageId(),
+		CorrelationId: config.correlationId,
+		ReplyTo:       config.replyTo,
+		AppId:         config.appId,
 	}
 
 	amqpContext, headers := producer.createProducerContext(ctx, config, queue, msg)
@@ -84,27 +256,130 @@ func (producer *Producer) produce(ctx context.Context, messageEnvelop MessageEnv
 
 	msg.Headers = headers
 
-	err = producer.channel.PublishWithContext(
-		amqpContext,
-		exchange,
-		key,
-		config.mandatory,
-		config.immediate,
+	outgoing := &OutgoingMessage{
+		Exchange:   exchange,
+		RoutingKey: key,
+		Mandatory:  config.mandatory,
+		Immediate:  config.immediate,
+		Publishing: msg,
+	}
+
+	var confirmation *Confirmation
+
+	err = producer.intercept(amqpContext, outgoing, func(ctx context.Context, outgoing *OutgoingMessage) (err error) {
+		confirmation, err = producer.send(ctx, channel, config, outgoing)
+		return err
+	})
+
+	if err != nil {
+		return outgoing.MessageId, nil, err
+	}
+
+	return outgoing.MessageId, confirmation, nil
+}
+
+// intercept runs the client's interceptors around send. An error returned
+// before send was reached is a veto, reported as ErrVetoed.
+func (producer *Producer) intercept(ctx context.Context, outgoing *OutgoingMessage, send Publish) error {
+	reached := false
+
+	publish := chainInterceptors(func(ctx context.Context, outgoing *OutgoingMessage) error {
+		reached = true
+		return send(ctx, outgoing)
+	}, producer.connection.config.interceptors...)
+
+	err := publish(ctx, outgoing)
+
+	if err != nil && !reached {
+		return handleError(producer.logger, ErrVetoed, err, "Publish vetoed by interceptor")
+	}
+
+	return err
+}
+
+func (producer *Producer) send(ctx context.Context, channel *amqp.Channel, config *ProducerConfiguration, outgoing *OutgoingMessage) (*Confirmation, error) {
+	msg := outgoing.Publishing
+
+	var pending *pendingReturn
+
+	if config.confirm && (outgoing.Mandatory || outgoing.Immediate) {
+		pending = producer.trackReturn(msg.MessageId)
+	}
+
+	deferred, err := channel.PublishWithDeferredConfirmWithContext(
+		ctx,
+		outgoing.Exchange,
+		outgoing.RoutingKey,
+		outgoing.Mandatory,
+		outgoing.Immediate,
 		msg,
 	)
 
-	failOnError(producer.logger, err, "Failed to publish message")
+	if err == nil && config.confirm && deferred == nil {
+		err = errors.New("channel is not in confirm mode")
+	}
+
+	if err != nil {
+		if pending != nil {
+			producer.untrackReturn(msg.MessageId)
+		}
+		return nil, handleError(producer.logger, ErrPublish, err, "Failed to publish message")
+	}
+
+	if deferred == nil {
+		return nil, nil
+	}
+
+	// A channel once put in confirm mode acks every publish, so the delivery
+	// tag is registered even when this publish does not wait for it.
+	confirmation := producer.awaitConfirm(channel, deferred.DeliveryTag, msg.MessageId, pending)
+
+	if !config.confirm {
+		return nil, nil
+	}
+
+	return confirmation, nil
 }
 
-func NewProducer(logger *logging.Logger, connectionString string) IProducer {
+func (producer *Producer) Close(ctx context.Context) error {
+	producer.mutex.Lock()
+
+	if producer.channel != nil && !producer.channel.IsClosed() {
+		if err := producer.channel.Close(); err != nil {
+			producer.logger.Standard.Warn().Err(err).Msg("Producer: Failed to close channel")
+		}
+	}
+
+	producer.mutex.Unlock()
+
+	return producer.connection.close(ctx)
+}
+
+// Deprecated: use TryNewProducer, which returns the connection error instead of panicking.
+func NewProducer(logger *logging.Logger, connectionString string, configure ...ConfigureClient) IProducer {
+	producer, err := TryNewProducer(logger, connectionString, configure...)
+
+	failOnError(logger, err, "Failed to create producer")
+
+	return producer
+}
+
+func TryNewProducer(logger *logging.Logger, connectionString string, configure ...ConfigureClient) (IProducer, error) {
+	connection, err := dial(logger, connectionString, configureClient(configure))
+
+	if err != nil {
+		return nil, err
+	}
+
 	producer := &Producer{
 		connectionString: connectionString,
-		connection:       new(amqp.Connection),
-		channel:          new(amqp.Channel),
+		connection:       connection,
 		logger:           logger,
+		pendingReturns:   make(map[string]*pendingReturn),
+		pendingConfirms:  make(map[confirmKey]*Confirmation),
+		earlyConfirms:    make(map[confirmKey]amqp.Confirmation),
+		declared:         newTopologyCache(),
 	}
 
-	producer.connect()
-
-	return producer
+	return producer, nil
 }
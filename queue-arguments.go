@@ -0,0 +1,334 @@
+package messaging
+
+import (
+	"fmt"
+	"time"
+
+	logging "github.com/mitz-it/golang-logging"
+)
+
+const (
+	messageTTLArgument           string = "x-message-ttl"
+	expiresArgument              string = "x-expires"
+	maxLengthArgument            string = "x-max-length"
+	maxLengthBytesArgument       string = "x-max-length-bytes"
+	overflowArgument             string = "x-overflow"
+	deadLetterExchangeArgument   string = "x-dead-letter-exchange"
+	deadLetterRoutingKeyArgument string = "x-dead-letter-routing-key"
+	singleActiveConsumerArgument string = "x-single-active-consumer"
+	queueTypeArgument            string = "x-queue-type"
+	maxPriorityArgument          string = "x-max-priority"
+	alternateExchangeArgument    string = "x-alternate-exchange"
+)
+
+type QueueType int
+
+const (
+	Classic QueueType = iota
+	Quorum
+	Stream
+)
+
+func (queueType QueueType) ToString() string {
+	if queueType < Classic || queueType > Stream {
+		return fmt.Sprintf("unknown(%d)", int(queueType))
+	}
+
+	queueTypes := []string{"classic", "quorum", "stream"}
+	return queueTypes[queueType]
+}
+
+type OverflowBehavior int
+
+const (
+	DropHead OverflowBehavior = iota
+	RejectPublish
+	RejectPublishDeadLetter
+)
+
+func (overflow OverflowBehavior) ToString() string {
+	if overflow < DropHead || overflow > RejectPublishDeadLetter {
+		return fmt.Sprintf("unknown(%d)", int(overflow))
+	}
+
+	behaviors := []string{"drop-head", "reject-publish", "reject-publish-dlx"}
+	return behaviors[overflow]
+}
+
+// knownQueueArguments lists the x-arguments the broker understands for queues,
+// so a misspelled key can be reported instead of being silently ignored.
+var knownQueueArguments = map[string]bool{
+	messageTTLArgument:                true,
+	expiresArgument:                   true,
+	maxLengthArgument:                 true,
+	maxLengthBytesArgument:            true,
+	overflowArgument:                  true,
+	deadLetterExchangeArgument:        true,
+	deadLetterRoutingKeyArgument:      true,
+	"x-dead-letter-strategy":          true,
+	singleActiveConsumerArgument:      true,
+	queueTypeArgument:                 true,
+	deliveryLimitArgument:             true,
+	maxPriorityArgument:               true,
+	"x-queue-mode":                    true,
+	"x-queue-version":                 true,
+	"x-queue-master-locator":          true,
+	"x-queue-leader-locator":          true,
+	"x-quorum-initial-group-size":     true,
+	"x-max-in-memory-length":          true,
+	"x-max-in-memory-bytes":           true,
+	"x-max-age":                       true,
+	"x-stream-max-segment-size-bytes": true,
+	"x-initial-cluster-size":          true,
+}
+
+func (config *queueConfiguration) MessageTTL(ttl time.Duration) *queueConfiguration {
+	return config.setArgument(messageTTLArgument, ttl.Milliseconds())
+}
+
+// Expires deletes the queue after it has been unused for the given duration.
+func (config *queueConfiguration) Expires(expires time.Duration) *queueConfiguration {
+	return config.setArgument(expiresArgument, expires.Milliseconds())
+}
+
+func (config *queueConfiguration) MaxLength(length int64) *queueConfiguration {
+	return config.setArgument(maxLengthArgument, length)
+}
+
+func (config *queueConfiguration) MaxLengthBytes(bytes int64) *queueConfiguration {
+	return config.setArgument(maxLengthBytesArgument, bytes)
+}
+
+func (config *queueConfiguration) Overflow(overflow OverflowBehavior) *queueConfiguration {
+	return config.setArgument(overflowArgument, overflow.ToString())
+}
+
+// DeadLetterExchange only sets the argument; use DeadLetter to also have the
+// dead-letter exchange and queues declared.
+func (config *queueConfiguration) DeadLetterExchange(exchange string) *queueConfiguration {
+	return config.setArgument(deadLetterExchangeArgument, exchange)
+}
+
+func (config *queueConfiguration) DeadLetterRoutingKey(routingKey string) *queueConfiguration {
+	return config.setArgument(deadLetterRoutingKeyArgument, routingKey)
+}
+
+func (config *queueConfiguration) SingleActiveConsumer(enabled bool) *queueConfiguration {
+	return config.setArgument(singleActiveConsumerArgument, enabled)
+}
+
+func (config *queueConfiguration) QueueType(queueType QueueType) *queueConfiguration {
+	return config.setArgument(queueTypeArgument, queueType.ToString())
+}
+
+// DeliveryLimit is enforced by the broker on quorum queues only, but consumers
+// without MaxDeliveries apply it themselves on any queue type.
+func (config *queueConfiguration) DeliveryLimit(limit int64) *queueConfiguration {
+	return config.setArgument(deliveryLimitArgument, limit)
+}
+
+// MaxPriority is only supported by classic queues.
+func (config *queueConfiguration) MaxPriority(priority uint8) *queueConfiguration {
+	return config.setArgument(maxPriorityArgument, int64(priority))
+}
+
+func (config *exchangeConfiguration) AlternateExchange(exchange string) *exchangeConfiguration {
+	if config.arguments == nil {
+		config.arguments = &Arguments{}
+	}
+
+	(*config.arguments)[alternateExchangeArgument] = exchange
+
+	return config
+}
+
+// setArgument adds to the arguments set so far. AddArguments replaces them,
+// so call it before the typed builders when combining both.
+func (config *queueConfiguration) setArgument(key string, value interface{}) *queueConfiguration {
+	if config.arguments == nil {
+		config.arguments = &Arguments{}
+	}
+
+	(*config.arguments)[key] = value
+
+	return config
+}
+
+// validate checks the value types and ranges of the queue's x-arguments and
+// the combinations the broker refuses, before anything is declared.
+func (config *queueConfiguration) validate() error {
+	if config.arguments == nil {
+		return nil
+	}
+
+	args := *config.arguments
+
+	for _, key := range []string{messageTTLArgument, maxLengthArgument, maxLengthBytesArgument, deliveryLimitArgument} {
+		if err := checkInteger(args, key, 0, -1); err != nil {
+			return err
+		}
+	}
+
+	if err := checkInteger(args, expiresArgument, 1, -1); err != nil {
+		return err
+	}
+
+	if err := checkInteger(args, maxPriorityArgument, 1, 255); err != nil {
+		return err
+	}
+
+	for _, key := range []string{deadLetterExchangeArgument, deadLetterRoutingKeyArgument} {
+		if err := checkString(args, key); err != nil {
+			return err
+		}
+	}
+
+	if value, ok := args[singleActiveConsumerArgument]; ok {
+		if _, isBool := value.(bool); !isBool {
+			return invalidArgument(singleActiveConsumerArgument, "must be a boolean, got %T", value)
+		}
+	}
+
+	if _, ok := args[deadLetterRoutingKeyArgument]; ok && config.deadLetter == nil {
+		if _, ok := args[deadLetterExchangeArgument]; !ok {
+			return invalidArgument(deadLetterRoutingKeyArgument, "requires %s", deadLetterExchangeArgument)
+		}
+	}
+
+	queueType, err := parseQueueType(args)
+
+	if err != nil {
+		return err
+	}
+
+	overflow, err := parseOverflow(args)
+
+	if err != nil {
+		return err
+	}
+
+	if queueType != Classic && (!config.durable || config.exclusive || config.autoDelete) {
+		return invalidArgument(queueTypeArgument, "%s queues must be durable, non-exclusive and not auto-delete", queueType.ToString())
+	}
+
+	if _, ok := args[maxPriorityArgument]; ok && queueType != Classic {
+		return invalidArgument(maxPriorityArgument, "is only supported by classic queues")
+	}
+
+	if overflow == RejectPublishDeadLetter && queueType == Quorum {
+		return invalidArgument(overflowArgument, "%s is not supported by quorum queues", overflow.ToString())
+	}
+
+	return nil
+}
+
+// warnUnknownArguments logs x-arguments the broker would silently ignore,
+// which usually means a typo.
+func (config *queueConfiguration) warnUnknownArguments(logger *logging.Logger) {
+	if config.arguments == nil {
+		return
+	}
+
+	for key := range *config.arguments {
+		if !knownQueueArguments[key] {
+			logger.Standard.Warn().Str("queue", config.name).Str("argument", key).Msg("Unknown queue argument")
+		}
+	}
+}
+
+func (config *exchangeConfiguration) validate() error {
+	if config.arguments == nil {
+		return nil
+	}
+
+	return checkString(*config.arguments, alternateExchangeArgument)
+}
+
+func parseQueueType(args Arguments) (QueueType, error) {
+	value, ok := args[queueTypeArgument]
+
+	if !ok {
+		return Classic, nil
+	}
+
+	for _, queueType := range []QueueType{Classic, Quorum, Stream} {
+		if value == queueType.ToString() {
+			return queueType, nil
+		}
+	}
+
+	return Classic, invalidArgument(queueTypeArgument, "must be classic, quorum or stream, got %v", value)
+}
+
+// parseOverflow returns DropHead, the broker default, when no policy is set.
+func parseOverflow(args Arguments) (OverflowBehavior, error) {
+	value, ok := args[overflowArgument]
+
+	if !ok {
+		return DropHead, nil
+	}
+
+	for _, overflow := range []OverflowBehavior{DropHead, RejectPublish, RejectPublishDeadLetter} {
+		if value == overflow.ToString() {
+			return overflow, nil
+		}
+	}
+
+	return DropHead, invalidArgument(overflowArgument, "must be drop-head, reject-publish or reject-publish-dlx, got %v", value)
+}
+
+// checkInteger accepts any integer type; max < 0 means unbounded.
+func checkInteger(args Arguments, key string, min int64, max int64) error {
+	value, ok := args[key]
+
+	if !ok {
+		return nil
+	}
+
+	var number int64
+
+	switch typed := value.(type) {
+	case int:
+		number = int64(typed)
+	case int8:
+		number = int64(typed)
+	case int16:
+		number = int64(typed)
+	case int32:
+		number = int64(typed)
+	case int64:
+		number = typed
+	case uint8:
+		number = int64(typed)
+	case uint16:
+		number = int64(typed)
+	case uint32:
+		number = int64(typed)
+	default:
+		return invalidArgument(key, "must be an integer, got %T", value)
+	}
+
+	if number < min || (max >= 0 && number > max) {
+		return invalidArgument(key, "value %d is out of range", number)
+	}
+
+	return nil
+}
+
+func checkString(args Arguments, key string) error {
+	value, ok := args[key]
+
+	if !ok {
+		return nil
+	}
+
+	if _, isString := value.(string); !isString {
+		return invalidArgument(key, "must be a string, got %T", value)
+	}
+
+	return nil
+}
+
+func invalidArgument(key string, format string, args ...interface{}) error {
+	return fmt.Errorf("%s "+format, append([]interface{}{key}, args...)...)
+}
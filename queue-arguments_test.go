@@ -0,0 +1,142 @@
+package messaging
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func durableQueue() *queueConfiguration {
+	return NewQueueConfiguration().Name("orders").Durable(true)
+}
+
+func TestQueueArgumentsValidate(t *testing.T) {
+	cases := []struct {
+		name     string
+		config   *queueConfiguration
+		expected string
+	}{
+		{"no arguments", NewQueueConfiguration(), ""},
+		{"typed builders", durableQueue().MessageTTL(time.Minute).MaxLength(10).Overflow(RejectPublish).SingleActiveConsumer(true), ""},
+
+		{"quorum durable", durableQueue().QueueType(Quorum), ""},
+		{"quorum transient", durableQueue().Durable(false).QueueType(Quorum), "quorum queues must be durable, non-exclusive and not auto-delete"},
+		{"quorum exclusive", durableQueue().Exclusive(true).QueueType(Quorum), "quorum queues must be durable"},
+		{"quorum auto-delete", durableQueue().AutoDelete(true).QueueType(Quorum), "quorum queues must be durable"},
+		{"stream transient", NewQueueConfiguration().QueueType(Stream), "stream queues must be durable"},
+		{"classic transient", NewQueueConfiguration().Exclusive(true).AutoDelete(true).QueueType(Classic), ""},
+		{"unknown queue type", durableQueue().AddArguments(&Arguments{queueTypeArgument: "lazy"}), "x-queue-type must be classic, quorum or stream, got lazy"},
+
+		{"priority on classic", durableQueue().MaxPriority(10), ""},
+		{"priority on quorum", durableQueue().QueueType(Quorum).MaxPriority(10), "x-max-priority is only supported by classic queues"},
+		{"priority on stream", durableQueue().QueueType(Stream).MaxPriority(10), "x-max-priority is only supported by classic queues"},
+
+		{"reject-publish-dlx on classic", durableQueue().Overflow(RejectPublishDeadLetter), ""},
+		{"reject-publish-dlx on quorum", durableQueue().QueueType(Quorum).Overflow(RejectPublishDeadLetter), "reject-publish-dlx is not supported by quorum queues"},
+		{"reject-publish on quorum", durableQueue().QueueType(Quorum).Overflow(RejectPublish), ""},
+		{"out of range queue type", durableQueue().QueueType(QueueType(7)), "x-queue-type must be classic, quorum or stream, got unknown(7)"},
+		{"out of range overflow", durableQueue().Overflow(OverflowBehavior(-1)), "x-overflow must be drop-head, reject-publish or reject-publish-dlx, got unknown(-1)"},
+		{"unknown overflow", durableQueue().AddArguments(&Arguments{overflowArgument: "drop-tail"}), "x-overflow must be drop-head, reject-publish or reject-publish-dlx, got drop-tail"},
+
+		{"zero ttl", durableQueue().AddArguments(&Arguments{messageTTLArgument: 0}), ""},
+		{"negative ttl", durableQueue().AddArguments(&Arguments{messageTTLArgument: -1}), "x-message-ttl value -1 is out of range"},
+		{"negative max length", durableQueue().MaxLength(-1), "x-max-length value -1 is out of range"},
+		{"negative max length bytes", durableQueue().MaxLengthBytes(-5), "x-max-length-bytes value -5 is out of range"},
+		{"negative delivery limit", durableQueue().DeliveryLimit(-1), "x-delivery-limit value -1 is out of range"},
+		{"zero expires", durableQueue().Expires(0), "x-expires value 0 is out of range"},
+		{"positive expires", durableQueue().Expires(time.Hour), ""},
+		{"zero priority", durableQueue().MaxPriority(0), "x-max-priority value 0 is out of range"},
+		{"max priority", durableQueue().MaxPriority(255), ""},
+		{"priority over range", durableQueue().AddArguments(&Arguments{maxPriorityArgument: 256}), "x-max-priority value 256 is out of range"},
+		{"int32 ttl", durableQueue().AddArguments(&Arguments{messageTTLArgument: int32(1000)}), ""},
+		{"uint16 max length", durableQueue().AddArguments(&Arguments{maxLengthArgument: uint16(10)}), ""},
+		{"float ttl", durableQueue().AddArguments(&Arguments{messageTTLArgument: 1000.0}), "x-message-ttl must be an integer, got float64"},
+		{"string max length", durableQueue().AddArguments(&Arguments{maxLengthArgument: "10"}), "x-max-length must be an integer, got string"},
+
+		{"string dead letter exchange", durableQueue().DeadLetterExchange("orders.dlx"), ""},
+		{"numeric dead letter exchange", durableQueue().AddArguments(&Arguments{deadLetterExchangeArgument: 1}), "x-dead-letter-exchange must be a string, got int"},
+		{"routing key without exchange", durableQueue().DeadLetterRoutingKey("failed"), "x-dead-letter-routing-key requires x-dead-letter-exchange"},
+		{"routing key with exchange", durableQueue().DeadLetterExchange("orders.dlx").DeadLetterRoutingKey("failed"), ""},
+		{"routing key with dead letter", durableQueue().DeadLetter(NewDeadLetterConfiguration()).DeadLetterRoutingKey("failed"), ""},
+		{"non-boolean single active consumer", durableQueue().AddArguments(&Arguments{singleActiveConsumerArgument: "true"}), "x-single-active-consumer must be a boolean, got string"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.config.validate()
+
+			if c.expected == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), c.expected) {
+				t.Fatalf("expected %q, got %v", c.expected, err)
+			}
+		})
+	}
+}
+
+func TestQueueArgumentNames(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected string
+	}{
+		{Classic.ToString(), "classic"},
+		{Quorum.ToString(), "quorum"},
+		{Stream.ToString(), "stream"},
+		{QueueType(3).ToString(), "unknown(3)"},
+		{QueueType(-1).ToString(), "unknown(-1)"},
+		{DropHead.ToString(), "drop-head"},
+		{RejectPublish.ToString(), "reject-publish"},
+		{RejectPublishDeadLetter.ToString(), "reject-publish-dlx"},
+		{OverflowBehavior(3).ToString(), "unknown(3)"},
+	}
+
+	for _, c := range cases {
+		if c.name != c.expected {
+			t.Errorf("expected %q, got %q", c.expected, c.name)
+		}
+	}
+}
+
+func TestExchangeArgumentsValidate(t *testing.T) {
+	cases := []struct {
+		name     string
+		config   *exchangeConfiguration
+		expected string
+	}{
+		{"no arguments", NewExchangeConfiguration(), ""},
+		{"alternate exchange", NewExchangeConfiguration().AlternateExchange("orders.unrouted"), ""},
+		{"numeric alternate exchange", NewExchangeConfiguration().AddArguments(&Arguments{alternateExchangeArgument: 1}), "x-alternate-exchange must be a string, got int"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.config.validate()
+
+			if c.expected == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), c.expected) {
+				t.Fatalf("expected %q, got %v", c.expected, err)
+			}
+		})
+	}
+}
+
+func TestUnknownQueueArgumentsAreAllowed(t *testing.T) {
+	config := durableQueue().AddArguments(&Arguments{"x-mesage-ttl": 1000})
+
+	if err := config.validate(); err != nil {
+		t.Fatalf("expected unknown arguments to be warned about, not rejected, got %v", err)
+	}
+
+	config.warnUnknownArguments(newTestLogger())
+}
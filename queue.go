@@ -12,6 +12,7 @@ type queueConfiguration struct {
 	exclusive  bool
 	noWait     bool
 	arguments  *Arguments
+	deadLetter *deadLetterConfiguration
 }
 
 func NewQueueConfiguration() *queueConfiguration {
@@ -22,16 +23,33 @@ func NewQueueConfiguration() *queueConfiguration {
 		exclusive:  defaultExclusive,
 		noWait:     defaultNoWait,
 		arguments:  nil,
+		deadLetter: nil,
 	}
 }
 
-func declareQueue(logger *logging.Logger, channel *amqp.Channel, config *queueConfiguration) *amqp.Queue {
+func declareQueue(logger *logging.Logger, channel *amqp.Channel, config *queueConfiguration) (*amqp.Queue, error) {
 	if config == nil {
-		return nil
+		return nil, nil
 	}
 
+	if err := config.validate(); err != nil {
+		return nil, handleError(logger, ErrInvalidConfig, err, "Invalid queue arguments")
+	}
+
+	config.warnUnknownArguments(logger)
+
 	args := toArgumentsTable(config.arguments)
 
+	if config.deadLetter != nil {
+		deadLetterArgs, err := declareDeadLetterTopology(logger, channel, config)
+
+		if err != nil {
+			return nil, err
+		}
+
+		args = toArgumentsTable(&deadLetterArgs)
+	}
+
 	queue, err := channel.QueueDeclare(
 		config.name,
 		config.durable,
@@ -41,9 +59,11 @@ func declareQueue(logger *logging.Logger, channel *amqp.Channel, config *queueCo
 		args,
 	)
 
-	failOnError(logger, err, "Failed do declare queue")
+	if err != nil {
+		return nil, handleError(logger, ErrDeclaration, err, "Failed to declare queue")
+	}
 
-	return &queue
+	return &queue, nil
 }
 
 func (config *queueConfiguration) Name(name string) *queueConfiguration {
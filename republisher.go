@@ -0,0 +1,126 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	logging "github.com/mitz-it/golang-logging"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// republisher moves deliveries to retry and parking lot queues. It publishes
+// mandatory on its own confirm-mode channel and waits for the broker, so the
+// original delivery is only acked once its copy was routed and confirmed.
+//
+// Publishes are serialized, hence the next confirm always belongs to the
+// publish being waited for. The client delivers a basic.return to its buffered
+// channel before handing over the ack that follows it, so once the ack was
+// received the return, if any, is already waiting.
+type republisher struct {
+	connection *connection
+	logger     *logging.Logger
+	mutex      sync.Mutex
+	channel    *amqp.Channel
+	returns    chan amqp.Return
+	confirms   chan amqp.Confirmation
+}
+
+func newRepublisher(connection *connection, logger *logging.Logger) *republisher {
+	return &republisher{
+		connection: connection,
+		logger:     logger,
+	}
+}
+
+func (publisher *republisher) publish(ctx context.Context, exchange string, key string, publishing amqp.Publishing) error {
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, defaultContextTimeOut*time.Second)
+	defer cancel()
+
+	channel, err := publisher.open(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if err := channel.PublishWithContext(ctx, exchange, key, true, false, publishing); err != nil {
+		publisher.discard()
+		return newError(ErrPublish, err, "Failed to republish message")
+	}
+
+	var confirmed amqp.Confirmation
+	var ok bool
+
+	select {
+	case confirmed, ok = <-publisher.confirms:
+	case <-ctx.Done():
+		publisher.discard()
+		return newError(ErrPublish, ctx.Err(), "Timed out waiting for republish confirmation")
+	}
+
+	if !ok {
+		publisher.discard()
+		return newError(ErrPublish, amqp.ErrClosed, "Channel closed before republish was confirmed")
+	}
+
+	select {
+	case returned, ok := <-publisher.returns:
+		if ok {
+			err := fmt.Errorf("%d %s (exchange %q, routing key %q)", returned.ReplyCode, returned.ReplyText, returned.Exchange, returned.RoutingKey)
+			return newError(ErrUnroutable, err, "Broker returned republished message")
+		}
+	default:
+	}
+
+	if !confirmed.Ack {
+		return newError(ErrNacked, errors.New("republished message was nacked"), "Broker nacked republished message")
+	}
+
+	return nil
+}
+
+func (publisher *republisher) open(ctx context.Context) (*amqp.Channel, error) {
+	if publisher.channel != nil && !publisher.channel.IsClosed() {
+		return publisher.channel, nil
+	}
+
+	channel, err := publisher.connection.channel(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := channel.Confirm(false); err != nil {
+		channel.Close()
+		return nil, handleError(publisher.logger, ErrConnection, err, "Consumer: Failed to enable publisher confirms")
+	}
+
+	publisher.channel = channel
+	publisher.returns = channel.NotifyReturn(make(chan amqp.Return, 1))
+	publisher.confirms = channel.NotifyPublish(make(chan amqp.Confirmation, 1))
+
+	return channel, nil
+}
+
+// discard drops a channel whose confirms can no longer be matched to
+// publishes, e.g. after a timeout, so a late ack is not mistaken for the next
+// publish's.
+func (publisher *republisher) discard() {
+	if publisher.channel != nil && !publisher.channel.IsClosed() {
+		publisher.channel.Close()
+	}
+
+	publisher.channel = nil
+}
+
+func (publisher *republisher) close() {
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+
+	publisher.discard()
+}
@@ -0,0 +1,173 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/google/uuid"
+	logging "github.com/mitz-it/golang-logging"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const directReplyTo string = "amq.rabbitmq.reply-to"
+const rpcErrorHeader string = "x-rpc-error"
+
+type IRequester interface {
+	Request(ctx context.Context, message any, configure ConfigureProducer) (*Message, error)
+	RequestWithEnvelop(ctx context.Context, messageEnvelop MessageEnvelop, configure ConfigureProducer) (*Message, error)
+	Gather(ctx context.Context, messageEnvelop MessageEnvelop, configure ConfigureProducer, gather ConfigureGather) ([]Reply, error)
+	Close(ctx context.Context) error
+}
+
+// Requester publishes requests through its own producer and correlates the
+// replies by CorrelationId. Replies arrive through RabbitMQ direct reply-to on
+// the publishing channel, or on an exclusive reply queue when configured.
+type Requester struct {
+	producer *Producer
+	logger   *logging.Logger
+	mutex    sync.Mutex
+	replyTo  string
+	pending  map[string]chan *Message
+}
+
+func (requester *Requester) Request(ctx context.Context, message any, configure ConfigureProducer) (*Message, error) {
+	messageEnvelop := MessageEnvelop{
+		Data: message,
+	}
+
+	return requester.RequestWithEnvelop(ctx, messageEnvelop, configure)
+}
+
+func (requester *Requester) RequestWithEnvelop(ctx context.Context, messageEnvelop MessageEnvelop, configure ConfigureProducer) (*Message, error) {
+	replies, correlationId, err := requester.send(ctx, messageEnvelop, configure, 1)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer requester.forget(correlationId)
+
+	select {
+	case reply := <-replies:
+		return reply, remoteError(reply)
+	case <-ctx.Done():
+		return nil, handleError(requester.logger, ErrNoReply, ctx.Err(), "Requester: Request timed out")
+	}
+}
+
+// send registers a correlation id able to buffer the given number of replies
+// and publishes the request with it.
+func (requester *Requester) send(ctx context.Context, messageEnvelop MessageEnvelop, configure ConfigureProducer, buffer int) (<-chan *Message, string, error) {
+	if _, err := requester.producer.getChannel(ctx, false); err != nil {
+		return nil, "", err
+	}
+
+	correlationId := uuid.New().String()
+	replies := make(chan *Message, buffer)
+
+	requester.mutex.Lock()
+	requester.pending[correlationId] = replies
+	replyTo := requester.replyTo
+	requester.mutex.Unlock()
+
+	err := requester.producer.TryProduceWithEnvelop(ctx, messageEnvelop, func(config *ProducerConfiguration) {
+		if configure != nil {
+			configure(config)
+		}
+		config.ReplyTo(replyTo).CorrelationId(correlationId)
+	})
+
+	if err != nil {
+		requester.forget(correlationId)
+		return nil, "", err
+	}
+
+	return replies, correlationId, nil
+}
+
+func (requester *Requester) forget(correlationId string) {
+	requester.mutex.Lock()
+	defer requester.mutex.Unlock()
+
+	delete(requester.pending, correlationId)
+}
+
+func (requester *Requester) listen(channel *amqp.Channel) error {
+	queue := directReplyTo
+
+	if requester.producer.connection.config.replyQueue {
+		declared, err := channel.QueueDeclare("", false, true, true, false, nil)
+
+		if err != nil {
+			return handleError(requester.logger, ErrDeclaration, err, "Requester: Failed to declare reply queue")
+		}
+
+		queue = declared.Name
+	}
+
+	replies, err := channel.Consume(queue, "", true, true, false, false, nil)
+
+	if err != nil {
+		return handleError(requester.logger, ErrConsume, err, "Requester: Failed to consume replies")
+	}
+
+	requester.mutex.Lock()
+	requester.replyTo = queue
+	requester.mutex.Unlock()
+
+	go requester.dispatch(replies)
+
+	return nil
+}
+
+func (requester *Requester) dispatch(replies <-chan amqp.Delivery) {
+	for delivery := range replies {
+		requester.mutex.Lock()
+		pending, ok := requester.pending[delivery.CorrelationId]
+		requester.mutex.Unlock()
+
+		if !ok {
+			requester.logger.Standard.Warn().Str("correlation-id", delivery.CorrelationId).Msg("Requester: Discarding uncorrelated reply")
+			continue
+		}
+
+		select {
+		case pending <- newMessage(delivery, defaultCodecs):
+		default:
+			requester.logger.Standard.Warn().Str("correlation-id", delivery.CorrelationId).Msg("Requester: Discarding unexpected reply")
+		}
+	}
+}
+
+func remoteError(reply *Message) error {
+	text, ok := reply.Headers[rpcErrorHeader].(string)
+
+	if !ok {
+		return nil
+	}
+
+	return newError(ErrRemote, errors.New(text), "Responder returned an error")
+}
+
+func (requester *Requester) Close(ctx context.Context) error {
+	return requester.producer.Close(ctx)
+}
+
+func TryNewRequester(logger *logging.Logger, connectionString string, configure ...ConfigureClient) (IRequester, error) {
+	producer, err := TryNewProducer(logger, connectionString, configure...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	requester := &Requester{
+		producer: producer.(*Producer),
+		logger:   logger,
+		pending:  make(map[string]chan *Message),
+	}
+
+	requester.producer.onChannelOpened = requester.listen
+
+	return requester, nil
+}
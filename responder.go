@@ -0,0 +1,37 @@
+package messaging
+
+import "context"
+
+type RespondFunc func(ctx context.Context, message *Message) (any, error)
+
+// Responder turns a RespondFunc into a Handler that publishes its result to the
+// request's ReplyTo address with the request's CorrelationId. A failing
+// RespondFunc is reported to the requester as an ErrRemote reply and the
+// request is still acked; only failing to publish the reply fails the
+// delivery.
+func Responder(producer IProducer, respond RespondFunc, configure ConfigureProducer) Handler {
+	return func(ctx context.Context, message *Message) error {
+		reply, err := respond(ctx, message)
+
+		if message.ReplyTo == "" {
+			return err
+		}
+
+		messageEnvelop := MessageEnvelop{
+			Data: reply,
+		}
+
+		if err != nil {
+			messageEnvelop.Headers = map[string]interface{}{rpcErrorHeader: err.Error()}
+		}
+
+		return producer.TryProduceWithEnvelop(ctx, messageEnvelop, func(config *ProducerConfiguration) {
+			if configure != nil {
+				configure(config)
+			}
+			config.ExchangeConfig = nil
+			config.QueueConfig = nil
+			config.Exchange(emptyExchangeName).RoutingKey(message.ReplyTo).CorrelationId(message.CorrelationId)
+		})
+	}
+}
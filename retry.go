@@ -0,0 +1,208 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"time"
+
+	logging "github.com/mitz-it/golang-logging"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const retryAttemptHeader string = "x-retry-attempt"
+
+type retryConfiguration struct {
+	maxAttempts  int
+	initialDelay time.Duration
+	maxDelay     time.Duration
+	multiplier   float64
+	jitter       float64
+}
+
+func NewRetryConfiguration() *retryConfiguration {
+	return &retryConfiguration{
+		maxAttempts:  defaultRetryMaxAttempts,
+		initialDelay: defaultRetryInitialDelay,
+		maxDelay:     defaultRetryMaxDelay,
+		multiplier:   defaultRetryMultiplier,
+		jitter:       defaultRetryJitter,
+	}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (err *permanentError) Error() string {
+	return err.err.Error()
+}
+
+func (err *permanentError) Unwrap() error {
+	return err.err
+}
+
+// Permanent marks a handler error as not worth retrying, so the delivery goes
+// straight to the consumer's FailureOutcome.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	var permanentErr *permanentError
+	return errors.As(err, &permanentErr)
+}
+
+func (config *retryConfiguration) delay(attempt int) time.Duration {
+	delay := float64(config.initialDelay) * math.Pow(config.multiplier, float64(attempt))
+	return time.Duration(math.Min(delay, float64(config.maxDelay)))
+}
+
+func (config *retryConfiguration) jittered(delay time.Duration) time.Duration {
+	return delay - time.Duration(rand.Float64()*config.jitter*float64(delay))
+}
+
+func (config *retryConfiguration) retryable(err error) bool {
+	var outcomeErr *outcomeError
+	return err != nil && !IsPermanent(err) && !errors.As(err, &outcomeErr)
+}
+
+func retryQueueName(queue string, delay time.Duration) string {
+	return fmt.Sprintf("%s.retry.%s", queue, delay)
+}
+
+// declareRetryQueues declares one delay queue per distinct backoff step. Each
+// queue holds messages for its TTL and dead-letters them back to the original
+// queue through the default exchange.
+func declareRetryQueues(logger *logging.Logger, channel *amqp.Channel, queue *amqp.Queue, config *ConsumerConfiguration) error {
+	if config.RetryConfig == nil {
+		return nil
+	}
+
+	if queue == nil {
+		return handleError(logger, ErrDeclaration, errors.New("retry requires a queue"), "Failed to declare retry queues")
+	}
+
+	declared := make(map[time.Duration]bool)
+
+	for attempt := 0; attempt < config.RetryConfig.maxAttempts; attempt++ {
+		delay := config.RetryConfig.delay(attempt)
+
+		if declared[delay] {
+			continue
+		}
+
+		args := Arguments{
+			"x-message-ttl":             delay.Milliseconds(),
+			"x-dead-letter-exchange":    emptyExchangeName,
+			"x-dead-letter-routing-key": queue.Name,
+		}
+
+		retryQueue := NewQueueConfiguration().
+			Name(retryQueueName(queue.Name, delay)).
+			Durable(config.QueueConfig.durable).
+			AddArguments(&args)
+
+		if _, err := declareQueue(logger, channel, retryQueue); err != nil {
+			return err
+		}
+
+		declared[delay] = true
+	}
+
+	return nil
+}
+
+func retryAttempt(headers amqp.Table) int {
+	switch attempt := headers[retryAttemptHeader].(type) {
+	case int:
+		return attempt
+	case int32:
+		return int(attempt)
+	case int64:
+		return int(attempt)
+	default:
+		return 0
+	}
+}
+
+func toPublishing(delivery amqp.Delivery) amqp.Publishing {
+	headers := amqp.Table{}
+
+	for key, value := range delivery.Headers {
+		headers[key] = value
+	}
+
+	return amqp.Publishing{
+		Headers:         headers,
+		ContentType:     delivery.ContentType,
+		ContentEncoding: delivery.ContentEncoding,
+		DeliveryMode:    delivery.DeliveryMode,
+		Priority:        delivery.Priority,
+		CorrelationId:   delivery.CorrelationId,
+		ReplyTo:         delivery.ReplyTo,
+		MessageId:       delivery.MessageId,
+		Timestamp:       delivery.Timestamp,
+		Type:            delivery.Type,
+		UserId:          delivery.UserId,
+		AppId:           delivery.AppId,
+		Body:            delivery.Body,
+	}
+}
+
+// retry republishes a failed delivery to the delay queue of its next attempt.
+// It reports false once the attempts are exhausted, leaving the caller to
+// settle the delivery some other way. When the copy was not confirmed as
+// routed, the original is requeued rather than acked.
+func (sub *subscription) retry(ctx context.Context, key string, message amqp.Delivery) (Outcome, bool) {
+	config := sub.config.RetryConfig
+	attempt := retryAttempt(message.Headers)
+
+	if attempt >= config.maxAttempts {
+		sub.consumer.logger.Standard.Warn().Str("message-id", message.MessageId).Int("attempts", attempt).Msg("Consumer: Retry attempts exhausted")
+		return sub.config.failureOutcome, false
+	}
+
+	delay := config.delay(attempt)
+
+	publishing := toPublishing(message)
+	publishing.Headers[retryAttemptHeader] = int32(attempt + 1)
+	publishing.Expiration = strconv.FormatInt(config.jittered(delay).Milliseconds(), 10)
+
+	err := sub.publish.publish(ctx, emptyExchangeName, retryQueueName(key, delay), publishing)
+
+	if err != nil {
+		handleError(sub.consumer.logger, ErrPublish, err, "Consumer: Failed to schedule retry")
+		return NackRequeue, true
+	}
+
+	return Ack, true
+}
+
+func (config *retryConfiguration) MaxAttempts(attempts int) *retryConfiguration {
+	config.maxAttempts = attempts
+	return config
+}
+
+func (config *retryConfiguration) InitialDelay(delay time.Duration) *retryConfiguration {
+	config.initialDelay = delay
+	return config
+}
+
+func (config *retryConfiguration) MaxDelay(delay time.Duration) *retryConfiguration {
+	config.maxDelay = delay
+	return config
+}
+
+func (config *retryConfiguration) Multiplier(multiplier float64) *retryConfiguration {
+	config.multiplier = multiplier
+	return config
+}
+
+func (config *retryConfiguration) Jitter(jitter float64) *retryConfiguration {
+	config.jitter = jitter
+	return config
+}
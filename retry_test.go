@@ -0,0 +1,115 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestRetryAttempt(t *testing.T) {
+	cases := []struct {
+		name     string
+		headers  amqp.Table
+		expected int
+	}{
+		{"no headers", nil, 0},
+		{"missing", amqp.Table{"other": int32(3)}, 0},
+		{"int", amqp.Table{retryAttemptHeader: 2}, 2},
+		{"int32", amqp.Table{retryAttemptHeader: int32(3)}, 3},
+		{"int64", amqp.Table{retryAttemptHeader: int64(4)}, 4},
+		{"string", amqp.Table{retryAttemptHeader: "5"}, 0},
+	}
+
+	for _, c := range cases {
+		if attempt := retryAttempt(c.headers); attempt != c.expected {
+			t.Errorf("%s: expected %d, got %d", c.name, c.expected, attempt)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	config := NewRetryConfiguration().InitialDelay(time.Second).Multiplier(2).MaxDelay(10 * time.Second)
+
+	cases := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{2, 4 * time.Second},
+		{3, 8 * time.Second},
+		{4, 10 * time.Second},
+		{10, 10 * time.Second},
+	}
+
+	for _, c := range cases {
+		if delay := config.delay(c.attempt); delay != c.expected {
+			t.Errorf("attempt %d: expected %s, got %s", c.attempt, c.expected, delay)
+		}
+	}
+}
+
+func TestRetryJitterStaysWithinBounds(t *testing.T) {
+	config := NewRetryConfiguration().Jitter(0.2)
+	delay := 10 * time.Second
+
+	for attempt := 0; attempt < 100; attempt++ {
+		if jittered := config.jittered(delay); jittered > delay || jittered < 8*time.Second {
+			t.Fatalf("expected a delay between 8s and 10s, got %s", jittered)
+		}
+	}
+
+	if jittered := NewRetryConfiguration().Jitter(0).jittered(delay); jittered != delay {
+		t.Fatalf("expected no jitter, got %s", jittered)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	failure := errors.New("handler failed")
+
+	cases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"success", nil, false},
+		{"plain error", failure, true},
+		{"wrapped error", fmt.Errorf("context: %w", failure), true},
+		{"permanent", Permanent(failure), false},
+		{"wrapped permanent", fmt.Errorf("context: %w", Permanent(failure)), false},
+		{"requested outcome", WithOutcome(failure, NackRequeue), false},
+		{"wrapped outcome", fmt.Errorf("context: %w", WithOutcome(failure, Reject)), false},
+	}
+
+	config := NewRetryConfiguration()
+
+	for _, c := range cases {
+		if retryable := config.retryable(c.err); retryable != c.expected {
+			t.Errorf("%s: expected %v, got %v", c.name, c.expected, retryable)
+		}
+	}
+}
+
+func TestRetryQueueName(t *testing.T) {
+	if name := retryQueueName("orders", 1500*time.Millisecond); name != "orders.retry.1.5s" {
+		t.Fatalf("unexpected retry queue name %q", name)
+	}
+}
+
+func TestRetryExhaustedFallsBackToFailureOutcome(t *testing.T) {
+	config := newConsumerConfiguration().FailureOutcome(NackNoRequeue)
+	config.RetryConfig = NewRetryConfiguration().MaxAttempts(3)
+
+	sub := &subscription{consumer: &Consumer{logger: newTestLogger()}, config: config}
+
+	// No republisher is set, so reaching the publish would panic.
+	outcome, scheduled := sub.retry(context.Background(), "orders", amqp.Delivery{Headers: amqp.Table{retryAttemptHeader: int32(3)}})
+
+	if scheduled || outcome != NackNoRequeue {
+		t.Fatalf("expected exhausted retries to use the failure outcome, got %s %v", outcome.ToString(), scheduled)
+	}
+}
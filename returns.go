@@ -0,0 +1,139 @@
+package messaging
+
+import (
+	"fmt"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type ReturnedMessage struct {
+	ReplyCode  uint16
+	ReplyText  string
+	Exchange   string
+	RoutingKey string
+	Message    *Message
+}
+
+type OnReturned func(returned ReturnedMessage)
+
+type pendingReturn struct {
+	mutex    sync.Mutex
+	returned *ReturnedMessage
+}
+
+func newReturnedMessage(ret amqp.Return) ReturnedMessage {
+	return ReturnedMessage{
+		ReplyCode:  ret.ReplyCode,
+		ReplyText:  ret.ReplyText,
+		Exchange:   ret.Exchange,
+		RoutingKey: ret.RoutingKey,
+		Message: &Message{
+			Metadata: Metadata{
+				Headers:       ret.Headers,
+				Exchange:      ret.Exchange,
+				RoutingKey:    ret.RoutingKey,
+				MessageId:     ret.MessageId,
+				CorrelationId: ret.CorrelationId,
+				ReplyTo:       ret.ReplyTo,
+				Timestamp:     ret.Timestamp,
+				ContentType:   ret.ContentType,
+				Type:          ret.Type,
+				AppId:         ret.AppId,
+			},
+			Body: ret.Body,
+		},
+	}
+}
+
+func (pending *pendingReturn) set(returned *ReturnedMessage) {
+	pending.mutex.Lock()
+	defer pending.mutex.Unlock()
+	pending.returned = returned
+}
+
+func (pending *pendingReturn) get() *ReturnedMessage {
+	pending.mutex.Lock()
+	defer pending.mutex.Unlock()
+	return pending.returned
+}
+
+func (pending *pendingReturn) err() error {
+	returned := pending.get()
+
+	if returned == nil {
+		return nil
+	}
+
+	err := fmt.Errorf("%d %s (exchange %q, routing key %q)", returned.ReplyCode, returned.ReplyText, returned.Exchange, returned.RoutingKey)
+
+	return newError(ErrUnroutable, err, "Broker returned message")
+}
+
+// trackReturn registers a confirmed mandatory publish so a basic.return for it
+// can fail the confirmation.
+func (producer *Producer) trackReturn(messageId string) *pendingReturn {
+	producer.returnsMutex.Lock()
+	defer producer.returnsMutex.Unlock()
+
+	pending := &pendingReturn{}
+	producer.pendingReturns[messageId] = pending
+
+	return pending
+}
+
+func (producer *Producer) untrackReturn(messageId string) {
+	producer.returnsMutex.Lock()
+	defer producer.returnsMutex.Unlock()
+
+	delete(producer.pendingReturns, messageId)
+}
+
+// listenChannel handles the returns and publisher confirms of one channel in a
+// single goroutine. Both notification channels are unbuffered, so the client
+// cannot hand over an ack before the preceding return was fully processed,
+// and a returned mandatory publish always fails its confirmation.
+func (producer *Producer) listenChannel(channel *amqp.Channel, returns <-chan amqp.Return, confirms <-chan amqp.Confirmation) {
+	defer producer.abandonConfirms(channel)
+
+	for returns != nil || confirms != nil {
+		select {
+		case ret, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			producer.returned(ret)
+		case confirmed, ok := <-confirms:
+			if !ok {
+				confirms = nil
+				continue
+			}
+			producer.confirmed(channel, confirmed)
+		}
+	}
+}
+
+func (producer *Producer) returned(ret amqp.Return) {
+	onReturned := producer.connection.config.onReturned
+	returned := newReturnedMessage(ret)
+
+	producer.returnsMutex.Lock()
+	pending, tracked := producer.pendingReturns[ret.MessageId]
+	producer.returnsMutex.Unlock()
+
+	if tracked {
+		pending.set(&returned)
+	}
+
+	// The listener is the only reader of the confirms channel, so a callback
+	// publishing with confirms through this producer would wait on itself.
+	if onReturned != nil {
+		go onReturned(returned)
+		return
+	}
+
+	if !tracked {
+		producer.logger.Standard.Warn().Str("message-id", ret.MessageId).Uint16("reply-code", ret.ReplyCode).Str("reply-text", ret.ReplyText).Msg("Producer: Unroutable message returned by broker")
+	}
+}
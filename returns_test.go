@@ -0,0 +1,114 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func startListener(producer *Producer) (*amqp.Channel, chan amqp.Return, chan amqp.Confirmation, chan struct{}) {
+	channel := new(amqp.Channel)
+	returns := make(chan amqp.Return)
+	confirms := make(chan amqp.Confirmation)
+	stopped := make(chan struct{})
+
+	go func() {
+		producer.listenChannel(channel, returns, confirms)
+		close(stopped)
+	}()
+
+	return channel, returns, confirms, stopped
+}
+
+func waitConfirmation(t *testing.T, confirmation *Confirmation) error {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	return confirmation.Wait(ctx)
+}
+
+func TestReturnedMandatoryPublishFailsConfirmation(t *testing.T) {
+	producer := newTestProducer()
+	channel, returns, confirms, _ := startListener(producer)
+
+	for attempt := 0; attempt < 100; attempt++ {
+		tag := uint64(attempt + 1)
+		pending := producer.trackReturn("message")
+		confirmation := producer.awaitConfirm(channel, tag, "message", pending)
+
+		returns <- amqp.Return{MessageId: "message", ReplyCode: 312, ReplyText: "NO_ROUTE"}
+		confirms <- amqp.Confirmation{DeliveryTag: tag, Ack: true}
+
+		if err := waitConfirmation(t, confirmation); !errors.Is(err, ErrUnroutable) {
+			t.Fatalf("attempt %d: expected ErrUnroutable, got %v", attempt, err)
+		}
+	}
+}
+
+func TestAckReceivedBeforeRegistration(t *testing.T) {
+	producer := newTestProducer()
+	channel, _, confirms, _ := startListener(producer)
+
+	confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+	confirms <- amqp.Confirmation{DeliveryTag: 2, Ack: false}
+
+	if err := waitConfirmation(t, producer.awaitConfirm(channel, 1, "acked", nil)); err != nil {
+		t.Fatalf("expected ack, got %v", err)
+	}
+
+	if err := waitConfirmation(t, producer.awaitConfirm(channel, 2, "nacked", nil)); !errors.Is(err, ErrNacked) {
+		t.Fatalf("expected ErrNacked, got %v", err)
+	}
+}
+
+func TestClosedChannelAbandonsConfirmations(t *testing.T) {
+	producer := newTestProducer()
+	channel, returns, confirms, stopped := startListener(producer)
+
+	confirmation := producer.awaitConfirm(channel, 1, "message", nil)
+
+	close(returns)
+	close(confirms)
+	<-stopped
+
+	if err := waitConfirmation(t, confirmation); !errors.Is(err, ErrNacked) {
+		t.Fatalf("expected ErrNacked, got %v", err)
+	}
+
+	if len(producer.pendingConfirms) != 0 {
+		t.Fatalf("expected no pending confirmations, got %d", len(producer.pendingConfirms))
+	}
+}
+
+func TestOnReturnedDoesNotBlockConfirms(t *testing.T) {
+	var channel *amqp.Channel
+	var producer *Producer
+	done := make(chan error, 1)
+
+	producer = newTestProducer(func(config *ClientConfiguration) {
+		config.OnReturned(func(message ReturnedMessage) {
+			// Republishing with confirms waits for the listener to deliver
+			// the next ack.
+			done <- waitConfirmation(t, producer.awaitConfirm(channel, 1, "rerouted", nil))
+		})
+	})
+
+	channel, returns, confirms, _ := startListener(producer)
+
+	returns <- amqp.Return{MessageId: "message", ReplyCode: 312, ReplyText: "NO_ROUTE"}
+
+	select {
+	case confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}:
+	case <-time.After(time.Second):
+		t.Fatal("expected the listener to keep reading confirms while OnReturned runs")
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("expected the republish to be confirmed, got %v", err)
+	}
+}
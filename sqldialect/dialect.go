@@ -0,0 +1,64 @@
+// Package sqldialect describes the SQL differences between the databases the
+// inbox and outbox packages support.
+package sqldialect
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Dialect struct {
+	name string
+}
+
+var (
+	SQLite   = Dialect{name: "sqlite"}
+	Postgres = Dialect{name: "postgres"}
+	MySQL    = Dialect{name: "mysql"}
+)
+
+func (dialect Dialect) Name() string {
+	return dialect.name
+}
+
+// Placeholder returns the bind parameter for the 1-based index.
+func (dialect Dialect) Placeholder(index int) string {
+	if dialect == Postgres {
+		return fmt.Sprintf("$%d", index)
+	}
+
+	return "?"
+}
+
+// Placeholders returns count comma separated bind parameters starting at from.
+func (dialect Dialect) Placeholders(from, count int) string {
+	placeholders := make([]string, 0, count)
+
+	for index := from; index < from+count; index++ {
+		placeholders = append(placeholders, dialect.Placeholder(index))
+	}
+
+	return strings.Join(placeholders, ", ")
+}
+
+// IsUniqueViolation reports whether err is a unique or primary key violation.
+// It matches the messages of the common drivers so no driver has to be
+// imported here.
+func (dialect Dialect) IsUniqueViolation(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	message := err.Error()
+
+	switch dialect {
+	case SQLite:
+		return strings.Contains(message, "UNIQUE constraint failed") || strings.Contains(message, "PRIMARY KEY constraint failed")
+	case Postgres:
+		return strings.Contains(message, "23505") || strings.Contains(message, "duplicate key value violates unique constraint")
+	case MySQL:
+		return strings.Contains(message, "Error 1062") || strings.Contains(message, "Duplicate entry")
+	}
+
+	return false
+}
@@ -0,0 +1,44 @@
+package sqldialect
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPlaceholders(t *testing.T) {
+	cases := []struct {
+		dialect  Dialect
+		expected string
+	}{
+		{SQLite, "?, ?, ?"},
+		{MySQL, "?, ?, ?"},
+		{Postgres, "$2, $3, $4"},
+	}
+
+	for _, c := range cases {
+		if placeholders := c.dialect.Placeholders(2, 3); placeholders != c.expected {
+			t.Errorf("%s: expected %q, got %q", c.dialect.Name(), c.expected, placeholders)
+		}
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	cases := []struct {
+		dialect  Dialect
+		err      error
+		expected bool
+	}{
+		{SQLite, errors.New("UNIQUE constraint failed: inbox_messages.message_id"), true},
+		{Postgres, errors.New(`pq: duplicate key value violates unique constraint "inbox_messages_pkey"`), true},
+		{Postgres, errors.New("ERROR: duplicate key (SQLSTATE 23505)"), true},
+		{MySQL, errors.New("Error 1062 (23000): Duplicate entry 'a' for key 'PRIMARY'"), true},
+		{SQLite, errors.New("database is locked"), false},
+		{Postgres, nil, false},
+	}
+
+	for _, c := range cases {
+		if result := c.dialect.IsUniqueViolation(c.err); result != c.expected {
+			t.Errorf("%s %v: expected %v, got %v", c.dialect.Name(), c.err, c.expected, result)
+		}
+	}
+}
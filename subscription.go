@@ -0,0 +1,310 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type subscription struct {
+	consumer *Consumer
+	config   *ConsumerConfiguration
+	channel  *amqp.Channel
+	key      string
+	tag      string
+	handler  Handler
+	publish  *republisher
+	mutex    sync.Mutex
+	declared string
+	bindings []*bindingConfiguration
+}
+
+func (sub *subscription) run(ctx context.Context, messages <-chan amqp.Delivery) error {
+	pool := sub.newWorkerPool(sub.key)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return sub.shutdown(messages, pool)
+		case message, ok := <-messages:
+			if !ok {
+				pool.stop()
+				return handleError(sub.consumer.logger, ErrConsume, amqp.ErrClosed, "Consumer: Delivery channel closed")
+			}
+
+			if !pool.submit(ctx, message) {
+				sub.requeue(message)
+				return sub.shutdown(messages, pool)
+			}
+		}
+	}
+}
+
+// handle receives the queue of the round the delivery arrived in rather than
+// reading it from the subscription, since a recovery replaces it while workers
+// of the previous round may still be running.
+func (sub *subscription) handle(key string, message amqp.Delivery) {
+	ctx := sub.consumer.createConsumeContext(context.Background(), sub.config, message, key)
+
+	if exceeded, count := sub.exceedsDeliveryLimit(message); exceeded {
+		outcome := sub.config.failureOutcome
+		if sub.canPark() {
+			outcome = sub.park(ctx, key, message, deliveryLimitReason(count))
+		}
+		sub.settle(message, outcome)
+		return
+	}
+
+	err := sub.invoke(ctx, message)
+	outcome := sub.resolve(ctx, key, message, err)
+	sub.settle(message, outcome)
+}
+
+func (sub *subscription) invoke(ctx context.Context, message amqp.Delivery) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = WithOutcome(fmt.Errorf("messaging: handler panicked: %v", recovered), sub.config.panicOutcome)
+		}
+	}()
+
+	return sub.handler(ctx, newMessage(message, sub.config.codecs))
+}
+
+func (sub *subscription) resolve(ctx context.Context, key string, message amqp.Delivery, err error) Outcome {
+	if err == nil {
+		return Ack
+	}
+
+	sub.consumer.logger.Standard.Error().Err(err).Str("message-id", message.MessageId).Msg("Consumer: Handler failed")
+
+	if sub.config.RetryConfig != nil && sub.config.RetryConfig.retryable(err) {
+		if outcome, scheduled := sub.retry(ctx, key, message); scheduled {
+			return outcome
+		}
+
+		if sub.canPark() {
+			return sub.park(ctx, key, message, err.Error())
+		}
+	}
+
+	return outcomeOf(err, sub.config.failureOutcome)
+}
+
+func (sub *subscription) settle(message amqp.Delivery, outcome Outcome) {
+	if sub.config.autoAck {
+		return
+	}
+
+	if !outcome.valid() {
+		sub.consumer.logger.Standard.Warn().Str("outcome", outcome.ToString()).Msg("Consumer: Unknown outcome, settling with the failure outcome")
+		outcome = sub.config.failureOutcome
+	}
+
+	var err error
+
+	switch outcome {
+	case Ack:
+		err = message.Ack(false)
+	case NackRequeue:
+		err = message.Nack(false, true)
+	case Reject:
+		err = message.Reject(false)
+	case NackNoRequeue:
+		err = message.Nack(false, false)
+	}
+
+	if err != nil {
+		sub.consumer.logger.Standard.Error().Err(err).Str("outcome", outcome.ToString()).Msg("Consumer: Failed to settle delivery")
+	}
+}
+
+func (sub *subscription) requeue(message amqp.Delivery) {
+	if !sub.config.autoAck {
+		message.Nack(false, true)
+	}
+}
+
+// shutdown stops the broker from pushing new deliveries, hands prefetched but
+// unprocessed deliveries back to the queue and waits up to the drain timeout
+// for in-flight handlers. Anything still unacknowledged when the channel is
+// closed is requeued by the broker.
+func (sub *subscription) shutdown(messages <-chan amqp.Delivery, pool *workerPool) error {
+	logger := sub.consumer.logger
+
+	if err := sub.channel.Cancel(sub.tag, false); err != nil {
+		logger.Standard.Warn().Err(err).Msg("Consumer: Failed to cancel consumer")
+	}
+
+	for message := range messages {
+		sub.requeue(message)
+	}
+
+	pool.stop()
+
+	if !pool.wait(sub.config.drainTimeout) {
+		logger.Standard.Warn().Msg("Consumer: Drain timeout elapsed, unacknowledged messages will be requeued")
+	}
+
+	return nil
+}
+
+// start opens a dedicated channel and replays the subscription's topology,
+// QoS and basic.consume on it. It runs for the first registration and again
+// after every recovery, so a restarted broker ends up with the same setup.
+func (sub *subscription) start(ctx context.Context) (<-chan amqp.Delivery, error) {
+	consumer := sub.consumer
+	config := sub.config
+
+	channel, err := consumer.connection.channel(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sub.channel = channel
+
+	messages, err := sub.setup(channel)
+
+	if err != nil {
+		sub.stop()
+		return nil, err
+	}
+
+	consumer.logger.Standard.Info().Str("queue", sub.key).Str("consumer-tag", sub.tag).Bool("auto-ack", config.autoAck).Msg("Waiting for messages")
+
+	return messages, nil
+}
+
+func (sub *subscription) setup(channel *amqp.Channel) (<-chan amqp.Delivery, error) {
+	logger := sub.consumer.logger
+	config := sub.config
+
+	if err := declareExchange(logger, channel, config.ExchangeConfig); err != nil {
+		return nil, err
+	}
+
+	queue, err := declareQueue(logger, channel, config.QueueConfig)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sub.key = config.getKey(queue)
+
+	if err := sub.bind(channel); err != nil {
+		return nil, err
+	}
+
+	if err := declareRetryQueues(logger, channel, queue, config); err != nil {
+		return nil, err
+	}
+
+	if err := config.configureQoS(channel, logger); err != nil {
+		return nil, err
+	}
+
+	if sub.tag == "" {
+		sub.tag = config.getConsumerTag()
+	}
+
+	messages, err := channel.Consume(
+		sub.key,
+		sub.tag,
+		config.autoAck,
+		config.exclusive,
+		config.noLocal,
+		config.noWait,
+		config.toArgumentsTable(),
+	)
+
+	if err != nil {
+		return nil, handleError(logger, ErrConsume, err, "Failed to register a consumer")
+	}
+
+	return messages, nil
+}
+
+// bind applies the subscription's current bindings, which may have changed at
+// runtime through Consumer.Bind, Unbind or Rebind since the last start.
+func (sub *subscription) bind(channel *amqp.Channel) error {
+	sub.mutex.Lock()
+	sub.declared = sub.key
+	bindings := append([]*bindingConfiguration{}, sub.bindings...)
+	sub.mutex.Unlock()
+
+	for _, binding := range bindings {
+		if err := binding.bind(sub.consumer.logger, channel, sub.key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (sub *subscription) queue() string {
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+
+	return sub.declared
+}
+
+func (sub *subscription) replaceBinding(from *bindingConfiguration, to *bindingConfiguration) {
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+
+	bindings := make([]*bindingConfiguration, 0, len(sub.bindings)+1)
+
+	for _, binding := range sub.bindings {
+		if (from != nil && binding.equals(from)) || (to != nil && binding.equals(to)) {
+			continue
+		}
+		bindings = append(bindings, binding)
+	}
+
+	if to != nil {
+		bindings = append(bindings, to)
+	}
+
+	sub.bindings = bindings
+}
+
+// recover waits for the connection to come back and restarts the
+// subscription. It returns nil deliveries when the context was cancelled while
+// waiting, and keeps retrying as long as failures are caused by the
+// connection dropping again.
+func (sub *subscription) recover(ctx context.Context) (<-chan amqp.Delivery, error) {
+	logger := sub.consumer.logger
+
+	for attempt := 0; ; attempt++ {
+		messages, err := sub.start(ctx)
+
+		if err == nil {
+			logger.Standard.Info().Str("queue", sub.key).Msg("Consumer: Subscription recovered")
+			return messages, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, nil
+		}
+
+		if errors.Is(err, ErrClosed) || errors.Is(err, errReconnectFailed) || (!errors.Is(err, ErrConnection) && sub.consumer.connection.isConnected()) {
+			return nil, err
+		}
+
+		select {
+		case <-time.After(sub.consumer.connection.config.ReconnectConfig.delay(attempt)):
+		case <-ctx.Done():
+			return nil, nil
+		}
+	}
+}
+
+func (sub *subscription) stop() {
+	if sub.channel != nil && !sub.channel.IsClosed() {
+		sub.channel.Close()
+	}
+}
@@ -0,0 +1,123 @@
+package messaging
+
+import (
+	"fmt"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// topologyCache remembers what was already declared on a channel so repeated
+// publishes skip the broker round-trips. Entries belong to a single channel and
+// are discarded as soon as a new one is opened, e.g. after a reconnect.
+type topologyCache struct {
+	mutex     sync.Mutex
+	channel   *amqp.Channel
+	exchanges map[string]bool
+	queues    map[string]amqp.Queue
+	bindings  map[string]bool
+}
+
+func newTopologyCache() *topologyCache {
+	return &topologyCache{
+		exchanges: make(map[string]bool),
+		queues:    make(map[string]amqp.Queue),
+		bindings:  make(map[string]bool),
+	}
+}
+
+func (cache *topologyCache) reset(channel *amqp.Channel) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.channel = channel
+	cache.exchanges = make(map[string]bool)
+	cache.queues = make(map[string]amqp.Queue)
+	cache.bindings = make(map[string]bool)
+}
+
+func (cache *topologyCache) hasExchange(channel *amqp.Channel, key string) bool {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	return cache.channel == channel && cache.exchanges[key]
+}
+
+func (cache *topologyCache) addExchange(channel *amqp.Channel, key string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if cache.channel == channel {
+		cache.exchanges[key] = true
+	}
+}
+
+func (cache *topologyCache) getQueue(channel *amqp.Channel, key string) (*amqp.Queue, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if cache.channel != channel || key == "" {
+		return nil, false
+	}
+
+	queue, ok := cache.queues[key]
+
+	return &queue, ok
+}
+
+func (cache *topologyCache) addQueue(channel *amqp.Channel, key string, queue *amqp.Queue) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if cache.channel == channel && key != "" && queue != nil {
+		cache.queues[key] = *queue
+	}
+}
+
+func (cache *topologyCache) hasBinding(channel *amqp.Channel, key string) bool {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	return cache.channel == channel && cache.bindings[key]
+}
+
+func (cache *topologyCache) addBinding(channel *amqp.Channel, key string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	if cache.channel == channel && key != "" {
+		cache.bindings[key] = true
+	}
+}
+
+func exchangeCacheKey(config *exchangeConfiguration) string {
+	return fmt.Sprintf("%s|%s|%t|%t|%t|%v", config.name, config.kind, config.durable, config.autoDelete, config.internal, toArgumentsTable(config.arguments))
+}
+
+// queueCacheKey is empty for queues that are never cached: server-named ones,
+// since every declaration creates a new queue, and auto-delete or expiring
+// ones, since the broker may delete them while the channel stays open and only
+// redeclaring them brings them back.
+func queueCacheKey(config *queueConfiguration) string {
+	if config.name == "" || config.autoDelete {
+		return ""
+	}
+
+	if config.arguments != nil {
+		if _, expires := (*config.arguments)[expiresArgument]; expires {
+			return ""
+		}
+	}
+
+	deadLetter := ""
+
+	if config.deadLetter != nil {
+		deadLetter = fmt.Sprintf("%+v", *config.deadLetter)
+	}
+
+	return fmt.Sprintf("%s|%t|%t|%t|%v|%s", config.name, config.durable, config.autoDelete, config.exclusive, toArgumentsTable(config.arguments), deadLetter)
+}
+
+func bindingCacheKey(queue string, routingKey string, exchange string, args amqp.Table) string {
+	return fmt.Sprintf("%s|%s|%s|%v", queue, routingKey, exchange, args)
+}
@@ -0,0 +1,233 @@
+package messaging
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestExchangeCacheKey(t *testing.T) {
+	base := func() *exchangeConfiguration {
+		return NewExchangeConfiguration().Name("orders").Kind(Topic).Durable(true)
+	}
+
+	key := exchangeCacheKey(base())
+
+	if key != exchangeCacheKey(base()) {
+		t.Fatal("expected equal configurations to share a key")
+	}
+
+	variants := map[string]*exchangeConfiguration{
+		"name":      base().Name("payments"),
+		"kind":      base().Kind(Direct),
+		"durable":   base().Durable(false),
+		"internal":  base().Internal(true),
+		"arguments": base().AlternateExchange("unrouted"),
+	}
+
+	for name, variant := range variants {
+		if exchangeCacheKey(variant) == key {
+			t.Errorf("%s: expected a different key", name)
+		}
+	}
+}
+
+func TestQueueCacheKey(t *testing.T) {
+	base := func() *queueConfiguration {
+		return NewQueueConfiguration().Name("orders").Durable(true)
+	}
+
+	key := queueCacheKey(base())
+
+	if key == "" || key != queueCacheKey(base()) {
+		t.Fatalf("expected a stable key, got %q", key)
+	}
+
+	variants := map[string]*queueConfiguration{
+		"name":        base().Name("payments"),
+		"durable":     base().Durable(false),
+		"exclusive":   base().Exclusive(true),
+		"arguments":   base().MaxLength(10),
+		"dead-letter": base().DeadLetter(NewDeadLetterConfiguration()),
+	}
+
+	for name, variant := range variants {
+		if queueCacheKey(variant) == key {
+			t.Errorf("%s: expected a different key", name)
+		}
+	}
+
+	uncached := map[string]*queueConfiguration{
+		"server-named": NewQueueConfiguration().Durable(true),
+		"auto-delete":  base().AutoDelete(true),
+		"expires":      base().Expires(time.Minute),
+	}
+
+	for name, config := range uncached {
+		if key := queueCacheKey(config); key != "" {
+			t.Errorf("%s: expected the queue not to be cached, got %q", name, key)
+		}
+	}
+}
+
+func TestBindingCacheKey(t *testing.T) {
+	key := bindingCacheKey("orders", "created", "events", nil)
+
+	if key != bindingCacheKey("orders", "created", "events", nil) {
+		t.Fatal("expected equal bindings to share a key")
+	}
+
+	variants := map[string]string{
+		"queue":       bindingCacheKey("payments", "created", "events", nil),
+		"routing-key": bindingCacheKey("orders", "deleted", "events", nil),
+		"exchange":    bindingCacheKey("orders", "created", "audit", nil),
+		"arguments":   bindingCacheKey("orders", "created", "events", amqp.Table{"x-match": "all"}),
+	}
+
+	for name, variant := range variants {
+		if variant == key {
+			t.Errorf("%s: expected a different key", name)
+		}
+	}
+}
+
+func TestTopologyCacheResetOnNewChannel(t *testing.T) {
+	cache := newTopologyCache()
+	first := new(amqp.Channel)
+	second := new(amqp.Channel)
+
+	cache.reset(first)
+	cache.addExchange(first, "exchange")
+	cache.addQueue(first, "queue", &amqp.Queue{Name: "orders"})
+	cache.addBinding(first, "binding")
+
+	if !cache.hasExchange(first, "exchange") || !cache.hasBinding(first, "binding") {
+		t.Fatal("expected declarations to be cached for the current channel")
+	}
+
+	if queue, ok := cache.getQueue(first, "queue"); !ok || queue.Name != "orders" {
+		t.Fatalf("expected cached queue, got %v %v", queue, ok)
+	}
+
+	cache.reset(second)
+
+	if cache.hasExchange(second, "exchange") || cache.hasBinding(second, "binding") {
+		t.Fatal("expected a new channel to start with an empty cache")
+	}
+
+	if _, ok := cache.getQueue(second, "queue"); ok {
+		t.Fatal("expected a new channel to start with an empty cache")
+	}
+
+	cache.addExchange(first, "stale")
+
+	if cache.hasExchange(second, "stale") || cache.hasExchange(first, "stale") {
+		t.Fatal("expected declarations made on a replaced channel to be ignored")
+	}
+}
+
+func TestProducerReusesCachedQueue(t *testing.T) {
+	producer := newTestProducer()
+	channel := new(amqp.Channel)
+	producer.declared.reset(channel)
+
+	config := NewQueueConfiguration().Name("orders").Durable(true)
+	producer.declared.addQueue(channel, queueCacheKey(config), &amqp.Queue{Name: "orders"})
+
+	// The channel is not connected, so reaching the broker would fail.
+	queue, err := producer.declareQueue(channel, config)
+
+	if err != nil || queue.Name != "orders" {
+		t.Fatalf("expected cached queue, got %v %v", queue, err)
+	}
+}
+
+func TestServerNamedQueueIsNotCached(t *testing.T) {
+	producer := newTestProducer()
+	channel := new(amqp.Channel)
+	producer.declared.reset(channel)
+
+	config := NewQueueConfiguration().Exclusive(true)
+	producer.declared.addQueue(channel, queueCacheKey(config), &amqp.Queue{Name: "amq.gen-1"})
+
+	if _, ok := producer.declared.getQueue(channel, queueCacheKey(config)); ok {
+		t.Fatal("expected a server-named queue to be declared again on every publish")
+	}
+}
+
+func TestDeletableQueueIsRedeclared(t *testing.T) {
+	producer := newTestProducer()
+	channel := new(amqp.Channel)
+	producer.declared.reset(channel)
+
+	for name, config := range map[string]*queueConfiguration{
+		"auto-delete": NewQueueConfiguration().Name("orders").AutoDelete(true),
+		"expires":     NewQueueConfiguration().Name("orders").Expires(time.Minute),
+	} {
+		producer.declared.addQueue(channel, queueCacheKey(config), &amqp.Queue{Name: "orders"})
+
+		if _, ok := producer.declared.getQueue(channel, queueCacheKey(config)); ok {
+			t.Errorf("%s: expected the queue to be declared again on every publish", name)
+		}
+	}
+
+	// bindQueueToExchange passes an empty key for bindings of such queues.
+	producer.declared.addBinding(channel, "")
+
+	if producer.declared.hasBinding(channel, "") {
+		t.Fatal("expected bindings of uncached queues not to be cached")
+	}
+}
+
+// BenchmarkProduceTopology compares publishing with the topology cache
+// against redeclaring the exchange, queue and binding on every message. It
+// needs a broker, set MESSAGING_AMQP_URL to run it.
+func BenchmarkProduceTopology(b *testing.B) {
+	url := os.Getenv("MESSAGING_AMQP_URL")
+
+	if url == "" {
+		b.Skip("MESSAGING_AMQP_URL is not set")
+	}
+
+	client, err := TryNewProducer(newTestLogger(), url)
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	producer := client.(*Producer)
+	defer producer.Close(context.Background())
+
+	configure := func(config *ProducerConfiguration) {
+		config.ExchangeConfig = NewExchangeConfiguration().Name("benchmark.topology").Kind(Direct).AutoDelete(true)
+		config.QueueConfig = NewQueueConfiguration().Name("benchmark.topology").AutoDelete(true).MaxLength(1)
+		config.RoutingKey("benchmark")
+	}
+
+	ctx := context.Background()
+
+	if err := producer.DeclareTopology(ctx, configure); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := producer.TryProduce(ctx, i, configure); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("redeclared", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			producer.declared.reset(producer.channel)
+
+			if err := producer.TryProduce(ctx, i, configure); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
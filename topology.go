@@ -0,0 +1,398 @@
+package messaging
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	logging "github.com/mitz-it/golang-logging"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"gopkg.in/yaml.v3"
+)
+
+type BindingDestination string
+
+const (
+	QueueDestination    BindingDestination = "queue"
+	ExchangeDestination BindingDestination = "exchange"
+)
+
+// Topology describes exchanges, queues and bindings declaratively, so services
+// can share one definition instead of repeating it in configure closures.
+type Topology struct {
+	Exchanges []ExchangeDefinition `json:"exchanges" yaml:"exchanges"`
+	Queues    []QueueDefinition    `json:"queues" yaml:"queues"`
+	Bindings  []BindingDefinition  `json:"bindings" yaml:"bindings"`
+}
+
+type ExchangeDefinition struct {
+	Name       string    `json:"name" yaml:"name"`
+	Kind       string    `json:"kind" yaml:"kind"`
+	Durable    bool      `json:"durable" yaml:"durable"`
+	AutoDelete bool      `json:"auto_delete" yaml:"auto_delete"`
+	Internal   bool      `json:"internal" yaml:"internal"`
+	Arguments  Arguments `json:"arguments" yaml:"arguments"`
+}
+
+type QueueDefinition struct {
+	Name       string    `json:"name" yaml:"name"`
+	Durable    bool      `json:"durable" yaml:"durable"`
+	AutoDelete bool      `json:"auto_delete" yaml:"auto_delete"`
+	Exclusive  bool      `json:"exclusive" yaml:"exclusive"`
+	Arguments  Arguments `json:"arguments" yaml:"arguments"`
+}
+
+// BindingDefinition binds Destination to the Source exchange. Destination is
+// a queue unless DestinationType is ExchangeDestination.
+type BindingDefinition struct {
+	Source          string             `json:"source" yaml:"source"`
+	Destination     string             `json:"destination" yaml:"destination"`
+	DestinationType BindingDestination `json:"destination_type" yaml:"destination_type"`
+	RoutingKey      string             `json:"routing_key" yaml:"routing_key"`
+	Arguments       Arguments          `json:"arguments" yaml:"arguments"`
+}
+
+// LoadTopology parses a YAML or JSON document and validates the result.
+func LoadTopology(data []byte) (*Topology, error) {
+	topology := &Topology{}
+
+	if err := yaml.Unmarshal(data, topology); err != nil {
+		return nil, newError(ErrInvalidConfig, err, "Failed to parse topology")
+	}
+
+	if err := topology.Validate(); err != nil {
+		return nil, err
+	}
+
+	return topology, nil
+}
+
+func LoadTopologyFile(path string) (*Topology, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, newError(ErrInvalidConfig, err, "Failed to read topology file")
+	}
+
+	return LoadTopology(data)
+}
+
+// Validate checks names, exchange kinds and that every binding references a
+// declared entity. Broker-provided amq.* exchanges may be referenced without
+// being declared. Plugin kinds (x-*) are accepted as they are.
+func (topology *Topology) Validate() error {
+	exchanges := make(map[string]bool)
+	queues := make(map[string]bool)
+
+	for _, exchange := range topology.Exchanges {
+		if exchange.Name == "" {
+			return invalidTopology("exchange without a name")
+		}
+
+		if exchanges[exchange.Name] {
+			return invalidTopology("exchange %q is defined twice", exchange.Name)
+		}
+
+		if _, err := ParseExchangeKind(exchange.Kind); err != nil && !isPluginExchangeKind(exchange.Kind) {
+			return invalidTopology("exchange %q has unknown kind %q", exchange.Name, exchange.Kind)
+		}
+
+		if err := exchange.configuration().validate(); err != nil {
+			return invalidTopology("exchange %q: %v", exchange.Name, err)
+		}
+
+		exchanges[exchange.Name] = true
+	}
+
+	for _, queue := range topology.Queues {
+		if queue.Name == "" {
+			return invalidTopology("queue without a name")
+		}
+
+		if queues[queue.Name] {
+			return invalidTopology("queue %q is defined twice", queue.Name)
+		}
+
+		if err := queue.configuration().validate(); err != nil {
+			return invalidTopology("queue %q: %v", queue.Name, err)
+		}
+
+		queues[queue.Name] = true
+	}
+
+	for _, binding := range topology.Bindings {
+		if !exchanges[binding.Source] && !isPredeclaredExchange(binding.Source) {
+			return invalidTopology("binding source exchange %q is not defined", binding.Source)
+		}
+
+		switch binding.destinationType() {
+		case QueueDestination:
+			if !queues[binding.Destination] {
+				return invalidTopology("binding destination queue %q is not defined", binding.Destination)
+			}
+		case ExchangeDestination:
+			if !exchanges[binding.Destination] && !isPredeclaredExchange(binding.Destination) {
+				return invalidTopology("binding destination exchange %q is not defined", binding.Destination)
+			}
+		default:
+			return invalidTopology("binding to %q has unknown destination type %q", binding.Destination, binding.DestinationType)
+		}
+	}
+
+	return nil
+}
+
+// Apply declares everything in the topology over the given channel. Declaring
+// an entity that already exists with the same settings is a no-op on the
+// broker, so Apply can run on every start-up.
+func (topology *Topology) Apply(logger *logging.Logger, channel *amqp.Channel) error {
+	if err := topology.Validate(); err != nil {
+		return err
+	}
+
+	for _, exchange := range topology.Exchanges {
+		if err := declareExchange(logger, channel, exchange.configuration()); err != nil {
+			return err
+		}
+	}
+
+	for _, queue := range topology.Queues {
+		if _, err := declareQueue(logger, channel, queue.configuration()); err != nil {
+			return err
+		}
+	}
+
+	for _, binding := range topology.Bindings {
+		if err := binding.apply(logger, channel); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Exchange returns a builder for the named exchange, ready to be assigned to
+// ExchangeConfig, or nil when the topology does not define it.
+func (topology *Topology) Exchange(name string) *exchangeConfiguration {
+	for _, exchange := range topology.Exchanges {
+		if exchange.Name == name {
+			return exchange.configuration()
+		}
+	}
+
+	return nil
+}
+
+// Queue returns a builder for the named queue, ready to be assigned to
+// QueueConfig, or nil when the topology does not define it.
+func (topology *Topology) Queue(name string) *queueConfiguration {
+	for _, queue := range topology.Queues {
+		if queue.Name == name {
+			return queue.configuration()
+		}
+	}
+
+	return nil
+}
+
+// AddExchange records an exchange built with NewExchangeConfiguration.
+func (topology *Topology) AddExchange(config *exchangeConfiguration) *Topology {
+	topology.addExchange(ExchangeDefinition{
+		Name:       config.name,
+		Kind:       exchangeKindName(config.kind),
+		Durable:    config.durable,
+		AutoDelete: config.autoDelete,
+		Internal:   config.internal,
+		Arguments:  copyOf(config.arguments),
+	})
+
+	return topology
+}
+
+// AddQueue records a queue built with NewQueueConfiguration. A queue with
+// DeadLetter configured also brings its dead-letter exchange, dead-letter and
+// parking lot queues and the binding between them, as declareQueue would.
+func (topology *Topology) AddQueue(config *queueConfiguration) *Topology {
+	arguments := copyOf(config.arguments)
+
+	if deadLetter := config.deadLetter; deadLetter != nil && config.name != "" {
+		exchange := deadLetter.exchangeName(config.name)
+		queue := deadLetter.queueName(config.name)
+		routingKey := deadLetter.routingKeyFor(config.name)
+
+		topology.addExchange(ExchangeDefinition{Name: exchange, Kind: Direct.ToString(), Durable: config.durable})
+		topology.addQueue(QueueDefinition{Name: queue, Durable: config.durable})
+		topology.addQueue(QueueDefinition{Name: deadLetter.parkingLotName(config.name), Durable: config.durable})
+		topology.Bind(BindingDefinition{Source: exchange, Destination: queue, DestinationType: QueueDestination, RoutingKey: routingKey})
+
+		if arguments == nil {
+			arguments = Arguments{}
+		}
+
+		arguments["x-dead-letter-exchange"] = exchange
+		arguments["x-dead-letter-routing-key"] = routingKey
+	}
+
+	topology.addQueue(QueueDefinition{
+		Name:       config.name,
+		Durable:    config.durable,
+		AutoDelete: config.autoDelete,
+		Exclusive:  config.exclusive,
+		Arguments:  arguments,
+	})
+
+	return topology
+}
+
+func (topology *Topology) Bind(binding BindingDefinition) *Topology {
+	for _, existing := range topology.Bindings {
+		if existing.Source == binding.Source && existing.Destination == binding.Destination &&
+			existing.destinationType() == binding.destinationType() && existing.RoutingKey == binding.RoutingKey {
+			return topology
+		}
+	}
+
+	topology.Bindings = append(topology.Bindings, binding)
+
+	return topology
+}
+
+func (topology *Topology) addExchange(definition ExchangeDefinition) {
+	for _, exchange := range topology.Exchanges {
+		if exchange.Name == definition.Name {
+			return
+		}
+	}
+
+	topology.Exchanges = append(topology.Exchanges, definition)
+}
+
+func (topology *Topology) addQueue(definition QueueDefinition) {
+	for _, queue := range topology.Queues {
+		if queue.Name == definition.Name {
+			return
+		}
+	}
+
+	topology.Queues = append(topology.Queues, definition)
+}
+
+func (definition ExchangeDefinition) configuration() *exchangeConfiguration {
+	config := NewExchangeConfiguration().
+		Name(definition.Name).
+		Durable(definition.Durable).
+		AutoDelete(definition.AutoDelete).
+		Internal(definition.Internal).
+		AddArguments(copyArguments(definition.Arguments))
+
+	config.kind = exchangeKindName(definition.Kind)
+
+	return config
+}
+
+func (definition QueueDefinition) configuration() *queueConfiguration {
+	return NewQueueConfiguration().
+		Name(definition.Name).
+		Durable(definition.Durable).
+		AutoDelete(definition.AutoDelete).
+		Exclusive(definition.Exclusive).
+		AddArguments(copyArguments(definition.Arguments))
+}
+
+func (definition BindingDefinition) destinationType() BindingDestination {
+	if definition.DestinationType == "" {
+		return QueueDestination
+	}
+
+	return definition.DestinationType
+}
+
+func (definition BindingDefinition) apply(logger *logging.Logger, channel *amqp.Channel) error {
+	args := toArgumentsTable(copyArguments(definition.Arguments))
+
+	if definition.destinationType() == ExchangeDestination {
+		err := channel.ExchangeBind(definition.Destination, definition.RoutingKey, definition.Source, false, args)
+		return handleError(logger, ErrDeclaration, err, "Failed to bind exchange to exchange")
+	}
+
+	err := channel.QueueBind(definition.Destination, definition.RoutingKey, definition.Source, false, args)
+
+	return handleError(logger, ErrDeclaration, err, "Failed to bind queue to exchange")
+}
+
+// copyArguments returns nil for empty arguments and converts nested maps
+// decoded from YAML/JSON into amqp.Table, the only map type the broker accepts.
+func copyArguments(arguments Arguments) *Arguments {
+	if len(arguments) == 0 {
+		return nil
+	}
+
+	copied := make(Arguments, len(arguments))
+
+	for key, value := range arguments {
+		copied[key] = toTableValue(value)
+	}
+
+	return &copied
+}
+
+func copyOf(arguments *Arguments) Arguments {
+	if arguments == nil || len(*arguments) == 0 {
+		return nil
+	}
+
+	copied := make(Arguments, len(*arguments))
+
+	for key, value := range *arguments {
+		copied[key] = value
+	}
+
+	return copied
+}
+
+// toTableValue also turns json.Number into int64 where possible, since the
+// broker rejects floating point values for arguments such as x-message-ttl.
+func toTableValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case json.Number:
+		if integer, err := typed.Int64(); err == nil {
+			return integer
+		}
+		if float, err := typed.Float64(); err == nil {
+			return float
+		}
+		return typed.String()
+	case map[string]interface{}:
+		return toTable(typed)
+	case Arguments:
+		// yaml.v3 decodes nested maps with the type of the enclosing map.
+		return toTable(typed)
+	case []interface{}:
+		values := make([]interface{}, len(typed))
+		for index, nested := range typed {
+			values[index] = toTableValue(nested)
+		}
+		return values
+	}
+
+	return value
+}
+
+func toTable(values map[string]interface{}) amqp.Table {
+	table := make(amqp.Table, len(values))
+
+	for key, nested := range values {
+		table[key] = toTableValue(nested)
+	}
+
+	return table
+}
+
+func isPredeclaredExchange(name string) bool {
+	return strings.HasPrefix(name, "amq.")
+}
+
+func invalidTopology(format string, args ...interface{}) error {
+	return newError(ErrInvalidConfig, fmt.Errorf(format, args...), "Invalid topology")
+}
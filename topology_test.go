@@ -0,0 +1,198 @@
+package messaging
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestLoadTopologyFixtures(t *testing.T) {
+	yamlTopology, err := LoadTopologyFile("testdata/topology.yaml")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jsonTopology, err := LoadTopologyFile("testdata/topology.json")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(yamlTopology, jsonTopology) {
+		t.Fatalf("expected the YAML and JSON fixtures to decode alike\nyaml: %+v\njson: %+v", yamlTopology, jsonTopology)
+	}
+
+	if len(yamlTopology.Exchanges) != 3 || len(yamlTopology.Queues) != 2 || len(yamlTopology.Bindings) != 4 {
+		t.Fatalf("unexpected topology %+v", yamlTopology)
+	}
+}
+
+func TestLoadTopologyArgumentTypes(t *testing.T) {
+	for _, path := range []string{"testdata/topology.yaml", "testdata/topology.json"} {
+		topology, err := LoadTopologyFile(path)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		queue := topology.Queue("orders.created")
+
+		if err := queue.validate(); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+
+		arguments := *topology.Queue("orders.audit").arguments
+
+		if _, isBool := arguments[singleActiveConsumerArgument].(bool); !isBool {
+			t.Errorf("%s: expected a boolean, got %T", path, arguments[singleActiveConsumerArgument])
+		}
+
+		if err := checkInteger(*queue.arguments, messageTTLArgument, 0, -1); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+
+		binding := copyArguments(topology.Bindings[2].Arguments)
+
+		if _, isTable := (*binding)["audit"].(amqp.Table); !isTable {
+			t.Errorf("%s: expected nested binding arguments as amqp.Table, got %T", path, (*binding)["audit"])
+		}
+
+		if err := toArgumentsTable(binding).Validate(); err != nil {
+			t.Errorf("%s: expected binding arguments the broker accepts, got %v", path, err)
+		}
+	}
+}
+
+func TestLoadTopologyBindings(t *testing.T) {
+	topology, err := LoadTopologyFile("testdata/topology.yaml")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		source      string
+		destination string
+		expected    BindingDestination
+	}{
+		{"orders", "orders.created", QueueDestination},
+		{"orders", "orders.audit", ExchangeDestination},
+		{"orders.audit", "orders.audit", QueueDestination},
+		{"amq.topic", "orders", ExchangeDestination},
+	}
+
+	for index, c := range cases {
+		binding := topology.Bindings[index]
+
+		if binding.Source != c.source || binding.Destination != c.destination || binding.destinationType() != c.expected {
+			t.Errorf("binding %d: expected %s -> %s (%s), got %+v", index, c.source, c.destination, c.expected, binding)
+		}
+	}
+}
+
+func TestLoadTopologyRejectsInvalidDocuments(t *testing.T) {
+	cases := []struct {
+		name     string
+		document string
+		expected string
+	}{
+		{
+			name:     "malformed",
+			document: "exchanges: [",
+			expected: "Failed to parse topology",
+		},
+		{
+			name:     "duplicate exchange",
+			document: "exchanges: [{name: orders, kind: topic}, {name: orders, kind: fanout}]",
+			expected: `exchange "orders" is defined twice`,
+		},
+		{
+			name:     "duplicate queue",
+			document: "queues: [{name: orders}, {name: orders}]",
+			expected: `queue "orders" is defined twice`,
+		},
+		{
+			name:     "unnamed exchange",
+			document: "exchanges: [{kind: topic}]",
+			expected: "exchange without a name",
+		},
+		{
+			name:     "unknown exchange kind",
+			document: "exchanges: [{name: orders, kind: random}]",
+			expected: `exchange "orders" has unknown kind "random"`,
+		},
+		{
+			name: "unknown destination type",
+			document: `{"exchanges": [{"name": "orders", "kind": "topic"}], "queues": [{"name": "created"}],
+				"bindings": [{"source": "orders", "destination": "created", "destination_type": "stream"}]}`,
+			expected: `unknown destination type "stream"`,
+		},
+		{
+			name:     "undefined source",
+			document: "queues: [{name: created}]\nbindings: [{source: orders, destination: created}]",
+			expected: `binding source exchange "orders" is not defined`,
+		},
+		{
+			name:     "undefined destination queue",
+			document: "exchanges: [{name: orders, kind: topic}]\nbindings: [{source: orders, destination: created}]",
+			expected: `binding destination queue "created" is not defined`,
+		},
+		{
+			name:     "undefined destination exchange",
+			document: "exchanges: [{name: orders, kind: topic}]\nbindings: [{source: orders, destination: audit, destination_type: exchange}]",
+			expected: `binding destination exchange "audit" is not defined`,
+		},
+		{
+			name:     "fractional ttl",
+			document: `{"queues": [{"name": "created", "arguments": {"x-message-ttl": 1.5}}]}`,
+			expected: "x-message-ttl must be an integer, got float64",
+		},
+		{
+			name:     "quoted ttl",
+			document: `queues: [{name: created, arguments: {x-message-ttl: "60000"}}]`,
+			expected: "x-message-ttl must be an integer, got string",
+		},
+		{
+			name:     "non-string alternate exchange",
+			document: "exchanges: [{name: orders, kind: topic, arguments: {x-alternate-exchange: 1}}]",
+			expected: "x-alternate-exchange must be a string, got int",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := LoadTopology([]byte(c.document))
+
+			if !errors.Is(err, ErrInvalidConfig) {
+				t.Fatalf("expected ErrInvalidConfig, got %v", err)
+			}
+
+			if !strings.Contains(err.Error(), c.expected) {
+				t.Fatalf("expected %q in %q", c.expected, err.Error())
+			}
+		})
+	}
+}
+
+func TestLoadTopologyAcceptsPredeclaredExchanges(t *testing.T) {
+	document := `
+queues: [{name: created}]
+bindings:
+  - {source: amq.direct, destination: created}
+  - {source: amq.topic, destination: amq.fanout, destination_type: exchange}
+`
+
+	if _, err := LoadTopology([]byte(document)); err != nil {
+		t.Fatalf("expected amq.* exchanges to be usable without being declared, got %v", err)
+	}
+}
+
+func TestLoadTopologyFileMissing(t *testing.T) {
+	if _, err := LoadTopologyFile("testdata/missing.yaml"); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("expected ErrInvalidConfig, got %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package messaging
+
+import (
+	"context"
+	"reflect"
+)
+
+type TypedHandler[T any] func(ctx context.Context, message T, metadata Metadata) error
+
+type Publisher[T any] struct {
+	producer  IProducer
+	configure ConfigureProducer
+}
+
+func NewPublisher[T any](producer IProducer, configure ConfigureProducer) *Publisher[T] {
+	return &Publisher[T]{
+		producer:  producer,
+		configure: configure,
+	}
+}
+
+func (publisher *Publisher[T]) Publish(ctx context.Context, message T) error {
+	return publisher.producer.TryProduce(ctx, message, publisher.configure)
+}
+
+func (publisher *Publisher[T]) PublishWithHeaders(ctx context.Context, message T, headers map[string]interface{}) error {
+	messageEnvelop := MessageEnvelop{
+		Headers: headers,
+		Data:    message,
+	}
+
+	return publisher.producer.TryProduceWithEnvelop(ctx, messageEnvelop, publisher.configure)
+}
+
+// Subscribe decodes every delivery into T before calling the handler. A body
+// that cannot be decoded never reaches the handler: it fails as a Permanent
+// error and is settled with the consumer's FailureOutcome.
+func Subscribe[T any](ctx context.Context, consumer IConsumer, configure ConfigureConsumer, handler TypedHandler[T]) error {
+	return consumer.ConsumeWithHandler(ctx, configure, func(ctx context.Context, message *Message) error {
+		payload, err := decodePayload[T](message)
+
+		if err != nil {
+			return Permanent(err)
+		}
+
+		return handler(ctx, payload, message.Metadata)
+	})
+}
+
+func decodePayload[T any](message *Message) (T, error) {
+	var payload T
+
+	if kind := reflect.TypeOf(payload); kind != nil && kind.Kind() == reflect.Pointer {
+		payload = reflect.New(kind.Elem()).Interface().(T)
+		return payload, message.Decode(payload)
+	}
+
+	return payload, message.Decode(&payload)
+}
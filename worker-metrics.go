@@ -0,0 +1,70 @@
+package messaging
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// WorkerMetrics tracks how busy a consumer's workers are. A single instance
+// may be shared by several subscriptions to aggregate them: each worker pool
+// adds its workers when it starts and removes them once they exited.
+type WorkerMetrics struct {
+	workers   atomic.Int64
+	busy      atomic.Int64
+	processed atomic.Int64
+	busyTime  atomic.Int64
+}
+
+func NewWorkerMetrics() *WorkerMetrics {
+	return &WorkerMetrics{}
+}
+
+func (metrics *WorkerMetrics) addWorkers(workers int) {
+	if metrics == nil {
+		return
+	}
+	metrics.workers.Add(int64(workers))
+}
+
+func (metrics *WorkerMetrics) begin() time.Time {
+	if metrics != nil {
+		metrics.busy.Add(1)
+	}
+	return time.Now()
+}
+
+func (metrics *WorkerMetrics) end(started time.Time) {
+	if metrics == nil {
+		return
+	}
+	metrics.busy.Add(-1)
+	metrics.processed.Add(1)
+	metrics.busyTime.Add(int64(time.Since(started)))
+}
+
+func (metrics *WorkerMetrics) Workers() int64 {
+	return metrics.workers.Load()
+}
+
+func (metrics *WorkerMetrics) Busy() int64 {
+	return metrics.busy.Load()
+}
+
+func (metrics *WorkerMetrics) Processed() int64 {
+	return metrics.processed.Load()
+}
+
+func (metrics *WorkerMetrics) BusyTime() time.Duration {
+	return time.Duration(metrics.busyTime.Load())
+}
+
+// Utilization is the share of workers handling a delivery right now.
+func (metrics *WorkerMetrics) Utilization() float64 {
+	workers := metrics.Workers()
+
+	if workers == 0 {
+		return 0
+	}
+
+	return float64(metrics.Busy()) / float64(workers)
+}
@@ -0,0 +1,44 @@
+package messaging
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSharedWorkerMetricsAggregatePools(t *testing.T) {
+	metrics := NewWorkerMetrics()
+
+	newSubscription := func(concurrency int) *subscription {
+		config := newConsumerConfiguration()
+		config.Concurrency(concurrency).WorkerMetrics(metrics)
+		return &subscription{config: config}
+	}
+
+	first := newSubscription(2).newWorkerPool("first")
+	second := newSubscription(3).newWorkerPool("second")
+
+	if workers := metrics.Workers(); workers != 5 {
+		t.Fatalf("expected 5 workers across both pools, got %d", workers)
+	}
+
+	first.stop()
+
+	if !first.wait(time.Second) {
+		t.Fatal("expected the stopped pool to drain")
+	}
+
+	if workers := metrics.Workers(); workers != 3 {
+		t.Fatalf("expected the stopped pool's workers to be removed, got %d", workers)
+	}
+
+	second.stop()
+	second.wait(time.Second)
+
+	if workers := metrics.Workers(); workers != 0 {
+		t.Fatalf("expected no workers, got %d", workers)
+	}
+
+	if utilization := metrics.Utilization(); utilization != 0 {
+		t.Fatalf("expected zero utilization, got %f", utilization)
+	}
+}
@@ -0,0 +1,122 @@
+package messaging
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// workerPool fans deliveries out to the subscription's configured number of
+// workers. Every delivery is settled through its own Acknowledger, i.e. on the
+// channel it arrived on, regardless of which worker handled it.
+//
+// Without a partition key all workers share one queue. With one, each worker
+// owns a queue and deliveries are hashed onto it by key, so deliveries sharing
+// a key are handled one after another while different keys run in parallel.
+type workerPool struct {
+	sub       *subscription
+	key       string
+	queues    []chan amqp.Delivery
+	workers   sync.WaitGroup
+	once      sync.Once
+	unkeyed   atomic.Uint32
+	partition PartitionKey
+}
+
+func (sub *subscription) newWorkerPool(key string) *workerPool {
+	concurrency := sub.config.concurrency
+
+	queues := 1
+	if sub.config.partitionKey != nil {
+		queues = concurrency
+	}
+
+	pool := &workerPool{
+		sub:       sub,
+		key:       key,
+		queues:    make([]chan amqp.Delivery, queues),
+		partition: sub.config.partitionKey,
+	}
+
+	for index := range pool.queues {
+		pool.queues[index] = make(chan amqp.Delivery)
+	}
+
+	sub.config.metrics.addWorkers(concurrency)
+
+	for worker := 0; worker < concurrency; worker++ {
+		pool.workers.Add(1)
+		go pool.work(pool.queues[worker%queues])
+	}
+
+	return pool
+}
+
+func (pool *workerPool) route(message amqp.Delivery) chan amqp.Delivery {
+	if len(pool.queues) == 1 {
+		return pool.queues[0]
+	}
+
+	key := pool.partition(newMessage(message, pool.sub.config.codecs))
+
+	if key == "" {
+		return pool.queues[pool.unkeyed.Add(1)%uint32(len(pool.queues))]
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+
+	return pool.queues[hash.Sum32()%uint32(len(pool.queues))]
+}
+
+func (pool *workerPool) work(queue <-chan amqp.Delivery) {
+	metrics := pool.sub.config.metrics
+
+	defer pool.workers.Done()
+	defer metrics.addWorkers(-1)
+
+	for message := range queue {
+		started := metrics.begin()
+		pool.sub.handle(pool.key, message)
+		metrics.end(started)
+	}
+}
+
+// submit hands a delivery to the next idle worker, giving up when the context
+// is cancelled first.
+func (pool *workerPool) submit(ctx context.Context, message amqp.Delivery) bool {
+	select {
+	case pool.route(message) <- message:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (pool *workerPool) stop() {
+	pool.once.Do(func() {
+		for _, queue := range pool.queues {
+			close(queue)
+		}
+	})
+}
+
+func (pool *workerPool) wait(timeout time.Duration) bool {
+	drained := make(chan struct{})
+
+	go func() {
+		pool.workers.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
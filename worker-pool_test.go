@@ -0,0 +1,135 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func keyedDelivery(key string, sequence int, acknowledger *recordingAcknowledger) amqp.Delivery {
+	headers := amqp.Table{"sequence": int32(sequence)}
+
+	if key != "" {
+		headers["key"] = key
+	}
+
+	return amqp.Delivery{Acknowledger: acknowledger, Headers: headers}
+}
+
+func newPartitionedSubscription(concurrency int, handler Handler) *subscription {
+	return &subscription{
+		consumer: &Consumer{logger: newTestLogger()},
+		config:   newConsumerConfiguration().AutoAck(false).Concurrency(concurrency).PartitionBy(HeaderPartitionKey("key")),
+		handler:  handler,
+	}
+}
+
+func TestWorkerPoolKeepsPerKeyOrder(t *testing.T) {
+	var mutex sync.Mutex
+	handled := map[string][]int{}
+	active := map[string]int{}
+
+	sub := newPartitionedSubscription(4, func(ctx context.Context, message *Message) error {
+		key, _ := message.Header("key")
+		sequence, _ := message.Header("sequence")
+		name := fmt.Sprint(key)
+
+		mutex.Lock()
+		active[name]++
+		if active[name] > 1 {
+			t.Errorf("key %s handled by two workers at once", name)
+		}
+		handled[name] = append(handled[name], int(sequence.(int32)))
+		mutex.Unlock()
+
+		time.Sleep(time.Duration(sequence.(int32)%3) * time.Millisecond)
+
+		mutex.Lock()
+		active[name]--
+		mutex.Unlock()
+
+		return nil
+	})
+
+	pool := sub.newWorkerPool("orders")
+	keys := []string{"a", "b", "c", "d", "e"}
+	acknowledgers := []*recordingAcknowledger{}
+
+	for sequence := 0; sequence < 20; sequence++ {
+		for _, key := range keys {
+			acknowledger := &recordingAcknowledger{}
+			acknowledgers = append(acknowledgers, acknowledger)
+
+			if !pool.submit(context.Background(), keyedDelivery(key, sequence, acknowledger)) {
+				t.Fatal("expected the delivery to be submitted")
+			}
+		}
+	}
+
+	pool.stop()
+
+	if !pool.wait(5 * time.Second) {
+		t.Fatal("expected the workers to drain")
+	}
+
+	for _, key := range keys {
+		if len(handled[key]) != 20 {
+			t.Fatalf("key %s: expected 20 deliveries, got %d", key, len(handled[key]))
+		}
+
+		for index, sequence := range handled[key] {
+			if sequence != index {
+				t.Fatalf("key %s: expected deliveries in order, got %v", key, handled[key])
+			}
+		}
+
+		first := pool.route(keyedDelivery(key, 0, nil))
+
+		for sequence := 1; sequence < 5; sequence++ {
+			if pool.route(keyedDelivery(key, sequence, nil)) != first {
+				t.Fatalf("key %s: expected every delivery to go to the same worker", key)
+			}
+		}
+	}
+
+	for index, acknowledger := range acknowledgers {
+		if acknowledger.settled != Ack.ToString() {
+			t.Fatalf("delivery %d: expected ack, got %q", index, acknowledger.settled)
+		}
+	}
+}
+
+func TestWorkerPoolSpreadsUnkeyedDeliveries(t *testing.T) {
+	sub := newPartitionedSubscription(4, func(ctx context.Context, message *Message) error {
+		return nil
+	})
+
+	pool := sub.newWorkerPool("orders")
+	defer pool.stop()
+
+	queues := map[chan amqp.Delivery]bool{}
+
+	for sequence := 0; sequence < len(pool.queues); sequence++ {
+		queues[pool.route(keyedDelivery("", sequence, nil))] = true
+	}
+
+	if len(queues) != len(pool.queues) {
+		t.Fatalf("expected unkeyed deliveries on all %d workers, got %d", len(pool.queues), len(queues))
+	}
+}
+
+func TestWorkerPoolWithoutPartitionSharesOneQueue(t *testing.T) {
+	sub := newPartitionedSubscription(4, nil)
+	sub.config.partitionKey = nil
+
+	pool := sub.newWorkerPool("orders")
+	defer pool.stop()
+
+	if len(pool.queues) != 1 || pool.route(keyedDelivery("a", 0, nil)) != pool.queues[0] {
+		t.Fatalf("expected one shared queue, got %d", len(pool.queues))
+	}
+}